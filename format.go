@@ -0,0 +1,358 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+
+// Format specifies the format of a configuration document.
+type Format int
+
+const (
+	// FormatAuto indicates the format must be detected.
+	FormatAuto Format = iota
+	FormatJSON
+	FormatYAML
+	FormatTOML
+)
+
+// -----------------------------------------------------------------------------
+
+// ReportFormat lets a SourceLoader indicate the format of the content it is returning. If a loader does not
+// report it, the format is guessed from the source's extension or from the content itself.
+func ReportFormat(ctx context.Context, format Format) {
+	if ls := getLoadedSource(ctx); ls != nil {
+		ls.format = format
+	}
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	case FormatTOML:
+		return "toml"
+	}
+	return "auto"
+}
+
+// parseFormat converts a format name into its value
+func parseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return FormatAuto, nil
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "toml":
+		return FormatTOML, nil
+	}
+	return FormatAuto, errors.New("unsupported format '" + name + "'")
+}
+
+// formatFromContentType gets the format from a MIME type
+func formatFromContentType(contentType string) Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return FormatAuto
+	}
+
+	switch mediaType {
+	case "application/json", "text/json":
+		return FormatJSON
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return FormatYAML
+	case "application/toml", "application/x-toml", "text/toml", "text/x-toml":
+		return FormatTOML
+	}
+
+	// Check structured syntax suffixes like application/vnd.company+json
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return FormatJSON
+	case strings.HasSuffix(mediaType, "+yaml"):
+		return FormatYAML
+	case strings.HasSuffix(mediaType, "+toml"):
+		return FormatTOML
+	}
+	return FormatAuto
+}
+
+// formatFromSource guesses the format of a source based on its extension
+func formatFromSource(source string) Format {
+	// Remove query and fragment from urls
+	if idx := strings.IndexAny(source, "?#"); idx >= 0 && len(getScheme(source)) > 0 {
+		source = source[:idx]
+	}
+
+	switch strings.ToLower(path.Ext(source)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatAuto
+}
+
+// detectFormat sniffs the content in order to guess its format
+func detectFormat(data []byte) Format {
+	dataLength := len(data)
+
+	for index := 0; index < dataLength; index++ {
+		switch data[index] {
+		case ' ', '\t', '\r', '\n':
+			// Skip blanks
+
+		case '#':
+			// Skip YAML and TOML comments
+			for index < dataLength && data[index] != '\n' {
+				index += 1
+			}
+
+		case '/':
+			// Skip JSON comments
+			if index+1 >= dataLength {
+				return FormatYAML
+			}
+			switch data[index+1] {
+			case '/':
+				for index < dataLength && data[index] != '\n' {
+					index += 1
+				}
+
+			case '*':
+				index += 2
+				for index+1 < dataLength && !(data[index] == '*' && data[index+1] == '/') {
+					index += 1
+				}
+				index += 1
+
+			default:
+				return FormatYAML
+			}
+
+		default:
+			// Got the first meaningful line
+			line := data[index:]
+			if eol := bytes.IndexByte(line, '\n'); eol >= 0 {
+				line = line[:eol]
+			}
+			if isTOMLTableHeader(line) {
+				return FormatTOML
+			}
+			if data[index] == '{' || data[index] == '[' {
+				return FormatJSON
+			}
+			if isTOMLKeyValue(line) {
+				return FormatTOML
+			}
+			return FormatYAML
+		}
+	}
+
+	// Empty documents are handled as JSON
+	return FormatJSON
+}
+
+// isTOMLTableHeader checks if the line is like [table] or [[array.of.tables]]
+func isTOMLTableHeader(line []byte) bool {
+	if idx := bytes.IndexByte(line, '#'); idx >= 0 {
+		line = line[:idx]
+	}
+	line = bytes.TrimSpace(line)
+
+	if bytes.HasPrefix(line, []byte("[[")) && bytes.HasSuffix(line, []byte("]]")) {
+		line = line[2 : len(line)-2]
+	} else if bytes.HasPrefix(line, []byte("[")) && bytes.HasSuffix(line, []byte("]")) {
+		line = line[1 : len(line)-1]
+	} else {
+		return false
+	}
+	line = bytes.TrimSpace(line)
+
+	// Reject JSON arrays containing literals or numbers
+	switch string(line) {
+	case "", "true", "false", "null":
+		return false
+	}
+	hasLetter := false
+	for _, ch := range line {
+		if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_' {
+			hasLetter = true
+		} else if !((ch >= '0' && ch <= '9') || ch == '-' || ch == '.') {
+			return false
+		}
+	}
+	return hasLetter
+}
+
+// isTOMLKeyValue checks if the line is like key = value
+func isTOMLKeyValue(line []byte) bool {
+	idx := bytes.IndexByte(line, '=')
+	if idx <= 0 {
+		return false
+	}
+	key := bytes.TrimSpace(line[:idx])
+	if len(key) == 0 {
+		return false
+	}
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return true
+	}
+	for _, ch := range key {
+		if !((ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') ||
+			ch == '_' || ch == '-' || ch == '.' || ch == ' ') {
+			return false
+		}
+	}
+	return true
+}
+
+// convertToJSON converts the data in the given format to JSON
+func convertToJSON(data []byte, f Format) ([]byte, error) {
+	if f == FormatAuto {
+		f = detectFormat(data)
+	}
+
+	switch f {
+	case FormatYAML:
+		return yamlToJSON(data)
+	case FormatTOML:
+		return tomlToJSON(data)
+	}
+
+	// Remove comments from json
+	removeComments(data)
+	return data, nil
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err = normalizeYAMLValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return encodeJSON(doc)
+}
+
+// normalizeYAMLValue converts maps with non-string keys into JSON compatible objects
+func normalizeYAMLValue(value interface{}) (interface{}, error) {
+	var err error
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key], err = normalizeYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			switch key.(type) {
+			case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+				return nil, fmt.Errorf("unsupported yaml key type '%T'", key)
+			}
+			m[fmt.Sprint(key)], err = normalizeYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+
+	case []interface{}:
+		for idx, item := range v {
+			v[idx], err = normalizeYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+func tomlToJSON(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+
+	_, err := toml.Decode(string(data), &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeJSON(normalizeTOMLValue(doc))
+}
+
+// normalizeTOMLValue converts dates and times into strings keeping the original layout
+func normalizeTOMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeTOMLValue(item)
+		}
+
+	case []map[string]interface{}:
+		arr := make([]interface{}, len(v))
+		for idx, item := range v {
+			arr[idx] = normalizeTOMLValue(item)
+		}
+		return arr
+
+	case []interface{}:
+		for idx, item := range v {
+			v[idx] = normalizeTOMLValue(item)
+		}
+
+	case time.Time:
+		// Local dates and times are decoded using special locations
+		switch v.Location().String() {
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		case "date-local":
+			return v.Format("2006-01-02")
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+
+	return value
+}
+
+func encodeJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
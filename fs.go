@@ -0,0 +1,112 @@
+package go_config_reader
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// getSessionFS returns the filesystem set in the options of the current load operation, if any
+func getSessionFS(ctx context.Context) fs.FS {
+	if s := getSession(ctx); s != nil {
+		return s.options.FS
+	}
+	return nil
+}
+
+// getSourceFS returns the filesystem set in the options if the source must be read from it. Embedded sources and
+// relative paths are read from it while absolute paths are read from the operating system's filesystem.
+func getSourceFS(ctx context.Context, source string) fs.FS {
+	fsys := getSessionFS(ctx)
+	if fsys == nil || strings.HasPrefix(source, "embed://") {
+		return fsys
+	}
+
+	if strings.HasPrefix(source, "dir://") {
+		source = source[6:]
+	} else {
+		source = strings.TrimPrefix(source, "file://")
+	}
+	if filepath.IsAbs(source) || strings.HasPrefix(source, "/") {
+		return nil
+	}
+	return fsys
+}
+
+// toFSPath converts a file path to the slash separated and unrooted form used by fs.FS
+func toFSPath(name string) (string, error) {
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if len(name) == 0 {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", errors.New("invalid path '" + name + "'")
+	}
+	return name, nil
+}
+
+// resolvePath converts a file path to absolute or, if a filesystem is provided, to the form it expects
+func resolvePath(fsys fs.FS, name string) (string, error) {
+	if fsys != nil {
+		return toFSPath(name)
+	}
+	return filepath.Abs(name)
+}
+
+// readFile reads a file from the given filesystem or from the operating system's one if nil
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	if fsys != nil {
+		return fs.ReadFile(fsys, name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// readDirEntries reads a directory from the given filesystem or from the operating system's one if nil. Entries
+// are sorted by name.
+func readDirEntries(fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	if fsys != nil {
+		return fs.ReadDir(fsys, name)
+	}
+	return os.ReadDir(name)
+}
+
+// statFile returns information about a file, following symbolic links, from the given filesystem or from the
+// operating system's one if nil
+func statFile(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if fsys != nil {
+		return fs.Stat(fsys, name)
+	}
+	return os.Stat(name)
+}
+
+// globFiles returns the names of the files matching a pattern in the given filesystem or in the operating system's
+// one if nil
+func globFiles(fsys fs.FS, pattern string) ([]string, error) {
+	if fsys != nil {
+		return fs.Glob(fsys, pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+// joinPath joins path elements using the separator of the given filesystem or of the operating system's one if nil
+func joinPath(fsys fs.FS, elem ...string) string {
+	if fsys != nil {
+		return path.Join(elem...)
+	}
+	return filepath.Join(elem...)
+}
+
+// loadFromEmbed loads a file, directory or pattern from the filesystem set in the options
+func loadFromEmbed(ctx context.Context, source string) ([]byte, error) {
+	if getSessionFS(ctx) == nil {
+		return nil, errors.New("embedded filesystem not set")
+	}
+	return fileLoader{}.Load(ctx, source)
+}
@@ -1,5 +1,19 @@
 package go_config_reader
 
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// kvPair is an entry read from a key-value store.
+type kvPair struct {
+	key   string
+	value []byte
+}
+
 // -----------------------------------------------------------------------------
 
 func removeComments(data []byte) {
@@ -60,3 +74,71 @@ func removeComments(data []byte) {
 		}
 	}
 }
+
+// readSecretFile reads a file containing a token or password and removes surrounding blanks
+func readSecretFile(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// overrideString replaces the destination string if the new value is not empty
+func overrideString(dest *string, value string) {
+	if len(value) > 0 {
+		*dest = value
+	}
+}
+
+// kvPairsToObject builds a nested object using the slash separated segments of the keys below the prefix. Values
+// are set as strings unless jsonValues is set, in which case they are decoded.
+func kvPairsToObject(pairs []kvPair, prefix string, jsonValues bool) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+
+	for _, pair := range pairs {
+		relKey := strings.TrimPrefix(pair.key, prefix)
+
+		// Skip folders
+		if len(relKey) == 0 || strings.HasSuffix(relKey, "/") {
+			continue
+		}
+
+		// Find or create the parent objects
+		segments := strings.Split(relKey, "/")
+		node := root
+		for _, segment := range segments[:len(segments)-1] {
+			child, ok := node[segment]
+			if !ok {
+				childObj := make(map[string]interface{})
+				node[segment] = childObj
+				node = childObj
+				continue
+			}
+			childObj, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("key '" + pair.key + "' conflicts with another one")
+			}
+			node = childObj
+		}
+
+		name := segments[len(segments)-1]
+		if _, ok := node[name]; ok {
+			return nil, errors.New("key '" + pair.key + "' conflicts with another one")
+		}
+
+		// Set the value
+		if jsonValues {
+			value, err := decodeJSON(pair.value)
+			if err != nil {
+				return nil, errors.New("invalid json value in key '" + pair.key + "'")
+			}
+			node[name] = value
+		} else {
+			node[name] = string(pair.value)
+		}
+	}
+
+	// Done
+	return root, nil
+}
@@ -10,70 +10,163 @@ import (
 
 type TagType int
 
+const (
+	stateNormal = iota
+	stateString
+	stateLiteralString
+	stateMultilineString
+	stateMultilineLiteralString
+	stateBlockScalar
+	stateLineComment
+	stateBlockComment
+)
+
 const (
 	TagSRC TagType = iota + 1
 	TagENV
+	TagVTRANSIT
+	TagFILE
+	TagCustom
 )
 
+// Mode indicates how the replacement of a tag must be inserted. It is set by adding a suffix to the tag name
+// like ${ENV|raw:NAME}.
+type Mode int
+
+const (
+	ModeAuto Mode = iota // Escaped if the tag is inside a string literal, else inserted as is
+	ModeRaw              // Always inserted as is
+	ModeJSON             // Always inserted as a string literal
+)
+
+// Syntax indicates the format of the data being processed. It determines how string literals and comments are
+// recognized.
+type Syntax int
+
+const (
+	SyntaxJSON Syntax = iota
+	SyntaxYAML
+	SyntaxTOML
+)
+
+// -----------------------------------------------------------------------------
+
+// tagNames maps the recognized tag names to their types
+var tagNames = map[string]TagType{
+	"SRC":      TagSRC,
+	"ENV":      TagENV,
+	"VTRANSIT": TagVTRANSIT,
+	"FILE":     TagFILE,
+}
+
+// modeNames maps the recognized tag mode suffixes to their values
+var modeNames = map[string]Mode{
+	"raw":  ModeRaw,
+	"json": ModeJSON,
+}
+
 // -----------------------------------------------------------------------------
 
 type Processor struct {
-	Data    []byte
-	dataLen int // Do length caching ourselves
-	idx     int // Current position
+	Data         []byte
+	dataLen      int                           // Do length caching ourselves
+	idx          int                           // Current position
+	syntax       Syntax                        // Format of the data
+	state        int                           // Lexical state at the current position
+	valueStart   bool                          // YAML: set if a scalar can start at the current position
+	flowLevel    int                           // YAML: nesting level of flow collections like [...] and {...}
+	parentIndent int                           // YAML: indentation of the line holding a block scalar indicator
+	blockIndent  int                           // YAML: indentation of the block scalar content or -1 if unknown yet
+	unescape     bool                          // Set if escaped tag starts must be replaced with literal ones
+	customTags   func(name string) interface{} // Returns the handler of a custom tag or nil if there is none
 }
 
 type TagInfo struct {
-	p       *Processor
-	Tag     TagType
-	start   int
-	end     int
-	Content []byte
+	p        *Processor
+	Tag      TagType
+	Name     string // Name of the tag, mainly used to identify custom tags
+	Mode     Mode
+	InString bool // Set if the tag is located inside a string literal
+	start    int
+	end      int
+	Content  []byte
+	Operator byte        // Shell-like operator of ENV tags, like '-' in ${ENV:NAME:-default}, or zero if none
+	Argument []byte      // Unexpanded text following the operator
+	Handler  interface{} // Handler of custom tags as returned by the customTags function
 }
 
 // -----------------------------------------------------------------------------
 
-func New(data []byte) *Processor {
+// New creates a new processor for data in the given syntax. If unescape is set, the escaped tag starts found while
+// scanning, $${, are replaced with literal ones, ${. Replacements are never scanned, so they are inserted as is. The
+// optional customTags function is called with the names of the tags other than the built-in ones and it must return
+// their handler, which is stored in the tag info, or nil if they must be left as is.
+func New(data []byte, syntax Syntax, unescape bool, customTags func(name string) interface{}) *Processor {
 	return &Processor{
-		Data:    data,
-		dataLen: len(data),
-		idx:     0,
+		Data:       data,
+		dataLen:    len(data),
+		idx:        0,
+		syntax:     syntax,
+		valueStart: true,
+		unescape:   unescape,
+		customTags: customTags,
 	}
 }
 
 func (p *Processor) NextTag() (*TagInfo, error) {
 	// Scan for the next tag
-	for p.idx+6 < p.dataLen {
-		// Check for tag
-		if p.Data[p.idx] == '$' && p.Data[p.idx+1] == '{' && p.Data[p.idx+5] == ':' {
-			switch p.Data[p.idx+2] {
-			case 'S':
-				// Check for SRC (source) tag
-				if p.Data[p.idx+3] == 'R' && p.Data[p.idx+4] == 'C' {
-					// Got a SRC tag
-					return p.getTagInfo(TagSRC, 6)
-				}
+	for p.idx < p.dataLen {
+		// Skip escaped tag starts like $${ removing the additional dollar sign if requested
+		if p.isEscapedTagStart(p.idx) {
+			if p.unescape {
+				p.removeAt(p.idx)
+			} else {
+				p.advance()
+			}
+			p.advance()
+			p.advance()
+			continue
+		}
 
-			case 'E':
-				// Check for ENV (environment variable) tag
-				if p.Data[p.idx+3] == 'N' && p.Data[p.idx+4] == 'V' {
-					// Got an ENV tag
-					return p.getTagInfo(TagENV, 6)
+		// Check for tag
+		if name, modeName, offset := p.scanTagStart(p.idx); offset > 0 {
+			if tag, ok := tagNames[string(name)]; ok {
+				return p.getTagInfo(tag, name, modeName, offset)
+			}
+			if p.customTags != nil {
+				if handler := p.customTags(string(name)); handler != nil {
+					ti, err := p.getTagInfo(TagCustom, name, modeName, offset)
+					if err != nil {
+						return nil, err
+					}
+					ti.Handler = handler
+					return ti, nil
 				}
 			}
 		}
 
 		// No tag found, advance to next character
-		p.idx += 1
+		p.advance()
 	}
 	return nil, io.EOF
 }
 
-func (p *Processor) getTagInfo(tag TagType, offset int) (*TagInfo, error) {
+func (p *Processor) getTagInfo(tag TagType, name []byte, modeName []byte, offset int) (*TagInfo, error) {
 	ti := TagInfo{
-		p:     p,
-		Tag:   tag,
-		start: p.idx,
+		p:        p,
+		Tag:      tag,
+		Name:     string(name),
+		InString: p.state == stateString || p.state == stateMultilineString,
+		start:    p.idx,
+	}
+
+	if modeName != nil {
+		var ok bool
+
+		ti.Mode, ok = modeNames[string(modeName)]
+		if !ok {
+			return nil, errors.New("unsupported tag mode '" + string(modeName) + "'")
+		}
 	}
 
 	// Skip tag start
@@ -81,19 +174,42 @@ func (p *Processor) getTagInfo(tag TagType, offset int) (*TagInfo, error) {
 
 	// Keep track of embedded tags
 	embeddedCounter := 0
+	operatorIdx := -1
 
 	// Calculate tag's content length and look for terminator
 	for p.idx < p.dataLen && (embeddedCounter != 0 || p.Data[p.idx] != '}') {
 
 		switch p.Data[p.idx] {
 		case '$': // Potential embedded tag
-			if p.idx+5 < p.dataLen && p.Data[p.idx+1] == '{' && p.Data[p.idx+5] == ':' {
+			// Escaped tags are not expanded but their terminators must be matched too
+			if p.isEscapedTagStart(p.idx) {
+				if _, _, embeddedOffset := p.scanTagStart(p.idx + 1); embeddedOffset > 0 {
+					embeddedCounter += 1
+
+					// Skip the escaped tag start
+					p.idx += 1 + embeddedOffset
+					continue
+				}
+			}
+			if _, _, embeddedOffset := p.scanTagStart(p.idx); embeddedOffset > 0 {
 				embeddedCounter += 1
+
+				// Skip the embedded tag start
+				p.idx += embeddedOffset
+				continue
 			}
 
 		case '}': // End of an embedded tag
 			embeddedCounter -= 1
 
+		case ':': // Potential operator of an ENV tag
+			if tag == TagENV && embeddedCounter == 0 && operatorIdx < 0 && p.idx+1 < p.dataLen {
+				switch p.Data[p.idx+1] {
+				case '-', '?', '+':
+					operatorIdx = p.idx
+				}
+			}
+
 		}
 
 		// Advance to next character
@@ -104,7 +220,13 @@ func (p *Processor) getTagInfo(tag TagType, offset int) (*TagInfo, error) {
 	}
 
 	// Set content. This is fast because slices in Go shares memory.
-	ti.Content = p.Data[ti.start+offset : p.idx]
+	if operatorIdx >= 0 {
+		ti.Content = p.Data[ti.start+offset : operatorIdx]
+		ti.Operator = p.Data[operatorIdx+1]
+		ti.Argument = p.Data[operatorIdx+2 : p.idx]
+	} else {
+		ti.Content = p.Data[ti.start+offset : p.idx]
+	}
 
 	// Skip end-of-tag character
 	p.idx += 1
@@ -112,10 +234,321 @@ func (p *Processor) getTagInfo(tag TagType, offset int) (*TagInfo, error) {
 	// Set end of tag location
 	ti.end = p.idx
 
+	// The replacement takes the place of a scalar
+	p.valueStart = false
+
 	// Return tag info
 	return &ti, nil
 }
 
+// advance moves to the next character keeping track of string literals and comments. JSON, YAML and TOML
+// double-quoted strings share the same escape sequences so the same rules apply to all of them.
+func (p *Processor) advance() {
+	ch := p.Data[p.idx]
+
+	switch p.state {
+	case stateNormal:
+		switch p.syntax {
+		case SyntaxYAML:
+			p.advanceYAML(ch)
+		case SyntaxTOML:
+			p.advanceTOML(ch)
+		default:
+			p.advanceJSON(ch)
+		}
+
+	case stateString:
+		if ch == '\\' {
+			if p.idx+1 < p.dataLen {
+				p.idx += 1 // Escaped character
+			}
+		} else if ch == '"' {
+			p.state = stateNormal
+		}
+
+	case stateLiteralString:
+		// YAML and TOML single-quoted strings have no escape sequences, so tags inside them are never escaped. YAML
+		// ones can span several lines and use two single quotes to represent one.
+		if p.syntax == SyntaxYAML {
+			if ch == '\'' {
+				if p.idx+1 < p.dataLen && p.Data[p.idx+1] == '\'' {
+					p.idx += 1
+				} else {
+					p.state = stateNormal
+				}
+			}
+		} else if ch == '\'' || ch == '\n' {
+			p.state = stateNormal
+		}
+
+	case stateMultilineString:
+		if ch == '\\' {
+			if p.idx+1 < p.dataLen {
+				p.idx += 1 // Escaped character
+			}
+		} else if n := p.countRepeated(p.idx, '"'); n >= 3 {
+			// Up to two quotes can precede the closing delimiter
+			p.state = stateNormal
+			p.idx += n - 1
+		}
+
+	case stateMultilineLiteralString:
+		if n := p.countRepeated(p.idx, '\''); n >= 3 {
+			p.state = stateNormal
+			p.idx += n - 1
+		}
+
+	case stateBlockScalar:
+		// The content of YAML block scalars has no escape sequences and lasts while lines are indented
+		if ch == '\n' && !p.isBlockScalarLine(p.idx+1) {
+			p.state = stateNormal
+			p.valueStart = true
+		}
+
+	case stateLineComment:
+		if ch == '\n' {
+			p.state = stateNormal
+			p.valueStart = true
+		}
+
+	case stateBlockComment:
+		if ch == '*' && p.idx+1 < p.dataLen && p.Data[p.idx+1] == '/' {
+			p.state = stateNormal
+			p.idx += 1
+		}
+	}
+
+	p.idx += 1
+}
+
+// advanceJSON handles a character outside string literals and comments of a JSON document. Comments are allowed.
+func (p *Processor) advanceJSON(ch byte) {
+	switch ch {
+	case '"':
+		p.state = stateString
+	case '/':
+		if p.idx+1 < p.dataLen {
+			switch p.Data[p.idx+1] {
+			case '/':
+				p.state = stateLineComment
+				p.idx += 1
+			case '*':
+				p.state = stateBlockComment
+				p.idx += 1
+			}
+		}
+	}
+}
+
+// advanceTOML handles a character outside string literals and comments of a TOML document. Quotes outside strings
+// always start a new one.
+func (p *Processor) advanceTOML(ch byte) {
+	switch ch {
+	case '"':
+		if p.countRepeated(p.idx, '"') >= 3 {
+			p.state = stateMultilineString
+			p.idx += 2
+		} else {
+			p.state = stateString
+		}
+	case '\'':
+		if p.countRepeated(p.idx, '\'') >= 3 {
+			p.state = stateMultilineLiteralString
+			p.idx += 2
+		} else {
+			p.state = stateLiteralString
+		}
+	case '#':
+		p.state = stateLineComment
+	}
+}
+
+// advanceYAML handles a character outside string literals and comments of a YAML document. Quotes only start a
+// string where a scalar can start, so the ones inside plain scalars like 5" screen are taken literally.
+func (p *Processor) advanceYAML(ch byte) {
+	switch ch {
+	case ' ', '\t', '\r':
+		// Blanks do not change the position of the next scalar
+
+	case '\n':
+		p.valueStart = true
+
+	case '#':
+		if p.idx == 0 || isBlank(p.Data[p.idx-1]) {
+			p.state = stateLineComment
+		} else {
+			p.valueStart = false
+		}
+
+	case '"':
+		if p.valueStart {
+			p.state = stateString
+		}
+		p.valueStart = false
+
+	case '\'':
+		if p.valueStart {
+			p.state = stateLiteralString
+		}
+		p.valueStart = false
+
+	case ':':
+		// Mapping values start after a colon followed by a blank or, in flow collections, after any colon
+		p.valueStart = p.flowLevel > 0 || p.idx+1 >= p.dataLen || isBlank(p.Data[p.idx+1])
+
+	case '-', '?':
+		// Sequence entries and complex keys are followed by a blank
+		p.valueStart = p.valueStart && (p.idx+1 >= p.dataLen || isBlank(p.Data[p.idx+1]))
+
+	case '[', '{':
+		if p.valueStart || p.flowLevel > 0 {
+			p.flowLevel += 1
+		}
+
+	case ']', '}':
+		if p.flowLevel > 0 {
+			p.flowLevel -= 1
+		}
+		p.valueStart = false
+
+	case ',':
+		p.valueStart = p.flowLevel > 0
+
+	case '|', '>':
+		if p.valueStart && p.flowLevel == 0 {
+			p.state = stateBlockScalar
+			p.parentIndent = p.lineIndent(p.idx)
+			p.blockIndent = -1
+		}
+		p.valueStart = false
+
+	case '&', '!':
+		// Anchors and tags precede the scalar
+		if p.valueStart {
+			for p.idx+1 < p.dataLen && !isBlank(p.Data[p.idx+1]) {
+				p.idx += 1
+			}
+		}
+
+	default:
+		p.valueStart = false
+	}
+}
+
+// isBlockScalarLine checks if the line starting at the given position belongs to the current block scalar. Empty
+// lines always do while the indentation of the first non-empty one sets the one of the rest.
+func (p *Processor) isBlockScalarLine(idx int) bool {
+	indent := 0
+	for idx+indent < p.dataLen && p.Data[idx+indent] == ' ' {
+		indent += 1
+	}
+	if idx+indent >= p.dataLen {
+		return false
+	}
+	if ch := p.Data[idx+indent]; ch == '\n' || ch == '\r' {
+		return true
+	}
+
+	if p.blockIndent < 0 {
+		if indent <= p.parentIndent {
+			return false
+		}
+		p.blockIndent = indent
+	}
+	return indent >= p.blockIndent
+}
+
+// lineIndent returns the number of spaces at the start of the line containing the given position
+func (p *Processor) lineIndent(idx int) int {
+	start := bytes.LastIndexByte(p.Data[:idx], '\n') + 1
+	indent := 0
+	for start+indent < idx && p.Data[start+indent] == ' ' {
+		indent += 1
+	}
+	return indent
+}
+
+// countRepeated returns the number of consecutive occurrences of a character starting at the given position
+func (p *Processor) countRepeated(idx int, ch byte) int {
+	n := 0
+	for idx+n < p.dataLen && p.Data[idx+n] == ch {
+		n += 1
+	}
+	return n
+}
+
+// scanTagStart checks if a tag start like ${NAME: or ${NAME|mode: is located at the given position and returns the
+// tag name and mode along with the length of the tag start. The returned length is zero if there is no tag start.
+func (p *Processor) scanTagStart(idx int) ([]byte, []byte, int) {
+	var modeName []byte
+
+	if idx+3 >= p.dataLen || p.Data[idx] != '$' || p.Data[idx+1] != '{' {
+		return nil, nil, 0
+	}
+
+	// Tag names start with an uppercase letter followed by uppercase letters, digits or underscores
+	end := idx + 2
+	for end < p.dataLen && IsTagNameChar(p.Data[end], end == idx+2) {
+		end += 1
+	}
+	if end == idx+2 || end >= p.dataLen {
+		return nil, nil, 0
+	}
+	name := p.Data[idx+2 : end]
+
+	// Modes are made of lowercase letters
+	if p.Data[end] == '|' {
+		modeStart := end + 1
+		end = modeStart
+		for end < p.dataLen && p.Data[end] >= 'a' && p.Data[end] <= 'z' {
+			end += 1
+		}
+		if end == modeStart || end >= p.dataLen {
+			return nil, nil, 0
+		}
+		modeName = p.Data[modeStart:end]
+	}
+
+	if p.Data[end] != ':' {
+		return nil, nil, 0
+	}
+	return name, modeName, end + 1 - idx
+}
+
+// IsTagNameChar checks if the given character is valid in a tag name
+func IsTagNameChar(ch byte, first bool) bool {
+	if ch >= 'A' && ch <= 'Z' {
+		return true
+	}
+	return !first && ((ch >= '0' && ch <= '9') || ch == '_')
+}
+
+// IsBuiltInTag checks if the given name belongs to a built-in tag
+func IsBuiltInTag(name string) bool {
+	_, ok := tagNames[name]
+	return ok
+}
+
+// isBlank checks if the given character is a space, a tab or a line break
+func isBlank(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'
+}
+
+// isEscapedTagStart checks if an escaped tag start like $${ is located at the given position
+func (p *Processor) isEscapedTagStart(idx int) bool {
+	return idx+2 < p.dataLen && p.Data[idx] == '$' && p.Data[idx+1] == '$' && p.Data[idx+2] == '{'
+}
+
+// removeAt removes the character at the given position. A new buffer is created because data may be shared with
+// the caller.
+func (p *Processor) removeAt(idx int) {
+	p.Data = bytes.Join([][]byte{
+		p.Data[:idx],
+		p.Data[idx+1:],
+	}, nil)
+	p.dataLen = len(p.Data)
+}
+
 func (ti *TagInfo) Replace(newContent []byte) {
 	// Update processor internals
 	if newContent == nil {
@@ -1,28 +1,146 @@
 package go_config_reader
 
-import "context"
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
 
 // -----------------------------------------------------------------------------
 
-func internalLoad(ctx context.Context, source string) (encodedJSON []byte, err error) {
-	// Try to load from web
-	encodedJSON, err = loadFromHttp(ctx, source)
+// SourceLoader is implemented by objects that can load configuration data from a source.
+type SourceLoader interface {
+	Load(ctx context.Context, source string) ([]byte, error)
+}
+
+// SourceLoaderFunc is an adapter to allow the use of ordinary functions as source loaders.
+type SourceLoaderFunc func(ctx context.Context, source string) ([]byte, error)
+
+// -----------------------------------------------------------------------------
+
+var loadersMtx = sync.RWMutex{}
+var loaders = make(map[string]SourceLoader)
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	RegisterLoader("http", httpLoader{})
+	RegisterLoader("https", httpLoader{})
+	RegisterLoader("vault", vaultLoader{})
+	RegisterLoader("vaults", vaultLoader{})
+	RegisterLoader("consul", consulLoader{})
+	RegisterLoader("consuls", consulLoader{})
+	RegisterLoader("etcd", etcdLoader{})
+	RegisterLoader("etcds", etcdLoader{})
+	RegisterLoader("s3", s3Loader{})
+	RegisterLoader("data", SourceLoaderFunc(loadFromData))
+	RegisterLoader("file", fileLoader{})
+	RegisterLoader("dir", dirLoader{})
+	RegisterLoader("embed", SourceLoaderFunc(loadFromEmbed))
+	RegisterLoader("stdin", SourceLoaderFunc(loadFromStdin))
+}
+
+// Load calls f(ctx, source).
+func (f SourceLoaderFunc) Load(ctx context.Context, source string) ([]byte, error) {
+	return f(ctx, source)
+}
+
+// RegisterLoader registers a loader for the given scheme. Scheme names are case-insensitive. Registering a
+// loader for an existing scheme replaces the previous one and passing a nil loader removes it. Sources without a
+// scheme are handled by the "file", "data" or "stdin" loaders and they receive the source as is.
+func RegisterLoader(scheme string, loader SourceLoader) {
+	scheme = strings.ToLower(scheme)
+
+	loadersMtx.Lock()
+	defer loadersMtx.Unlock()
+
+	if loader != nil {
+		loaders[scheme] = loader
+	} else {
+		delete(loaders, scheme)
+	}
+}
+
+// internalLoad loads the given source and returns its content along with the format reported by the loader or
+// guessed from the source's extension.
+func internalLoad(ctx context.Context, source string) ([]byte, Format, error) {
+	loader, err := lookupLoader(ctx, source)
+	if err != nil {
+		return nil, FormatAuto, err
+	}
 
-	if err == ErrWrongFormat {
-		// If source is not a web url, try to load from hashicorp vault url
-		encodedJSON, err = loadFromVault(ctx, source)
+	ls := &loadedSource{
+		loader: loader,
+		source: source,
+	}
+	data, err := loader.Load(withLoadedSource(ctx, ls), source)
+	if err != nil {
+		return nil, FormatAuto, err
 	}
 
-	if err == ErrWrongFormat {
-		// If source is not a hashicorp vault url, try to load from a data url
-		encodedJSON, err = loadFromData(source)
+	// Keep track of the loaded sources
+	if s := getSession(ctx); s != nil {
+		s.sources = append(s.sources, ls)
 	}
 
-	if err == ErrWrongFormat {
-		// At last, try to load from a file
-		encodedJSON, err = loadFromFile(ctx, source)
+	if ls.format == FormatAuto {
+		ls.format = formatFromSource(source)
 	}
 
 	// Done
-	return
+	return data, ls.format, nil
+}
+
+func lookupLoader(ctx context.Context, source string) (SourceLoader, error) {
+	scheme := getScheme(source)
+	if len(scheme) == 0 {
+		// If no scheme was specified, a single dash stands for the standard input, else try to guess a JSON or
+		// assume it is a file. The source is passed as is to the loader of the chosen scheme.
+		if source == "-" {
+			scheme = "stdin"
+		} else if looksLikeJSON(source) {
+			scheme = "data"
+		} else {
+			scheme = "file"
+		}
+	}
+
+	// Loaders specified in the options have preference
+	if s := getSession(ctx); s != nil && s.options.Loaders != nil {
+		if loader, ok := s.options.Loaders[scheme]; ok && loader != nil {
+			return loader, nil
+		}
+	}
+
+	loadersMtx.RLock()
+	loader, ok := loaders[scheme]
+	loadersMtx.RUnlock()
+	if !ok {
+		return nil, errors.New("unsupported source scheme '" + scheme + "'")
+	}
+
+	// Done
+	return loader, nil
+}
+
+// getScheme returns the lowercase scheme of a source or an empty string if it has not one.
+func getScheme(source string) string {
+	idx := strings.Index(source, "://")
+	if idx <= 0 {
+		return ""
+	}
+
+	for i := 0; i < idx; i++ {
+		ch := source[i]
+		if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') {
+			continue
+		}
+		if i > 0 && ((ch >= '0' && ch <= '9') || ch == '+' || ch == '-' || ch == '.') {
+			continue
+		}
+		return ""
+	}
+
+	return strings.ToLower(source[:idx])
 }
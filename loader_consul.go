@@ -0,0 +1,319 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	consulBlockingWait      = 5 * time.Minute
+	consulBlockingTimeSlack = 30 * time.Second
+	consulMinQueryInterval  = time.Second
+	consulTokenEnvVar       = "CONSUL_HTTP_TOKEN"
+)
+
+// -----------------------------------------------------------------------------
+
+type consulLoader struct{}
+
+// consulSource holds the parameters of a consul url.
+type consulSource struct {
+	address    string
+	key        string
+	prefix     bool // Set if the key ends with a slash so all the keys below it are loaded
+	token      string
+	datacenter string
+	jsonValues bool // Set if values of a prefix must be decoded as json instead of loaded as strings
+	format     Format
+}
+
+// consulState holds the information needed to detect changes in a loaded key or prefix.
+type consulState struct {
+	src   *consulSource
+	index uint64
+	hash  [sha256.Size]byte
+}
+
+// consulKVPair is an entry returned by the KV endpoint when a prefix is read.
+type consulKVPair struct {
+	Key   string
+	Value []byte
+}
+
+// -----------------------------------------------------------------------------
+
+var consulClient *http.Client
+var consulClientOnce sync.Once
+
+// errConsulKeyNotFound is returned by queryConsul when the key or prefix does not exist
+var errConsulKeyNotFound = errors.New("key not found")
+
+// -----------------------------------------------------------------------------
+
+// Load loads the value of a key or all the keys below a prefix.
+func (consulLoader) Load(ctx context.Context, source string) ([]byte, error) {
+	return loadFromConsul(ctx, source)
+}
+
+// watch uses blocking queries in order to detect changes in the loaded key or prefix.
+func (consulLoader) watch(ctx context.Context, ls *loadedSource, notify func()) error {
+	state, ok := ls.state.(*consulState)
+	if !ok {
+		return nil
+	}
+
+	opts := getReloadOptions(ctx)
+	failures := 0
+	for {
+		startTime := time.Now()
+
+		data, index, err := queryConsul(ctx, state.src, state.index, consulBlockingWait)
+		if err != nil && err != errConsulKeyNotFound {
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures += 1
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(pollDelay(opts, failures)):
+			}
+			continue
+		}
+		failures = 0
+
+		// Reset the index if it goes backwards as recommended by the consul documentation
+		unchanged := index == state.index
+		if index < state.index {
+			index = 0
+		}
+		state.index = index
+
+		hash := sha256.Sum256(data)
+		if !bytes.Equal(hash[:], state.hash[:]) {
+			state.hash = hash
+			notify()
+		}
+
+		// Avoid flooding the server if it does not block
+		if unchanged {
+			if elapsed := time.Since(startTime); elapsed < consulMinQueryInterval {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(consulMinQueryInterval - elapsed):
+				}
+			}
+		}
+	}
+}
+
+// loadFromConsul tries to load the content from a Consul KV store
+func loadFromConsul(ctx context.Context, source string) ([]byte, error) {
+	src, err := parseConsulSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	data, index, err := queryConsul(ctx, src, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefixes are always returned as a json object
+	if src.prefix {
+		ReportFormat(ctx, FormatJSON)
+	} else {
+		ReportFormat(ctx, src.format)
+	}
+
+	// Keep track of the modify index in order to watch for changes
+	if ls := getLoadedSource(ctx); ls != nil {
+		ls.state = &consulState{
+			src:   src,
+			index: index,
+			hash:  sha256.Sum256(data),
+		}
+	}
+
+	// Done
+	return data, nil
+}
+
+func parseConsulSource(source string) (*consulSource, error) {
+	var scheme string
+
+	if strings.HasPrefix(source, "consul://") {
+		scheme = "http"
+	} else if strings.HasPrefix(source, "consuls://") {
+		scheme = "https"
+	} else {
+		return nil, ErrWrongFormat
+	}
+
+	u, err := url.Parse(scheme + source[strings.Index(source, "://"):])
+	if err != nil {
+		return nil, err
+	}
+	if len(u.Host) == 0 {
+		return nil, errors.New("invalid url")
+	}
+
+	src := consulSource{
+		address: scheme + "://" + u.Host,
+		key:     strings.TrimPrefix(u.Path, "/"),
+		token:   os.Getenv(consulTokenEnvVar),
+	}
+	src.prefix = len(src.key) == 0 || strings.HasSuffix(src.key, "/")
+
+	// Parse query params
+	for k, v := range u.Query() {
+		if len(v) > 0 {
+			switch k {
+			case "token":
+				src.token = v[0]
+
+			case "dc":
+				src.datacenter = v[0]
+
+			case "values":
+				switch v[0] {
+				case "string":
+					src.jsonValues = false
+				case "json":
+					src.jsonValues = true
+				default:
+					return nil, errors.New("invalid values mode")
+				}
+
+			case "format":
+				src.format, err = parseFormat(v[0])
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Done
+	return &src, nil
+}
+
+// queryConsul reads a key or a prefix. If index is not zero, the request blocks until the content changes or
+// the wait time elapses. It returns the content along with the index of the last modification.
+func queryConsul(ctx context.Context, src *consulSource, index uint64, wait time.Duration) ([]byte, uint64, error) {
+	consulClientOnce.Do(func() {
+		// Blocking queries send the response headers when the wait time elapses, so the response header timeout
+		// used by the http loader cannot be applied
+		httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+		httpTransport.MaxIdleConns = 10
+		httpTransport.MaxConnsPerHost = 10
+		httpTransport.IdleConnTimeout = 60 * time.Second
+		httpTransport.MaxIdleConnsPerHost = 10
+
+		consulClient = &http.Client{
+			Transport: httpTransport,
+		}
+	})
+
+	// Build the request url
+	segments := strings.Split(src.key, "/")
+	for idx := range segments {
+		segments[idx] = url.PathEscape(segments[idx])
+	}
+	query := url.Values{}
+	if src.prefix {
+		query.Set("recurse", "true")
+	} else {
+		query.Set("raw", "true")
+	}
+	if len(src.datacenter) > 0 {
+		query.Set("dc", src.datacenter)
+	}
+	timeout := httpRequestTimeout
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", strconv.FormatInt(int64(wait/time.Second), 10)+"s")
+
+		// Consul adds a random time up to wait/16 to the wait time
+		timeout = wait + wait/16 + consulBlockingTimeSlack
+	}
+
+	req, err := http.NewRequest("GET", src.address+"/v1/kv/"+strings.Join(segments, "/")+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(src.token) > 0 {
+		req.Header.Set("X-Consul-Token", src.token)
+	}
+
+	// Execute request
+	ctxWithTimeout, ctxCancel := context.WithTimeout(ctx, timeout)
+	defer ctxCancel()
+	resp, err := consulClient.Do(req.WithContext(ctxWithTimeout))
+	if err != nil {
+		return nil, 0, err
+	}
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	// Check if the request succeeded
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, newIndex, errConsulKeyNotFound
+	default:
+		return nil, 0, fmt.Errorf("unexpected HTTP status code [http-status=%v]", resp.Status)
+	}
+
+	// Single keys are returned as is
+	if !src.prefix {
+		return responseBody, newIndex, nil
+	}
+
+	// Convert the keys below the prefix into a json object
+	var pairs []consulKVPair
+
+	err = json.Unmarshal(responseBody, &pairs)
+	if err != nil {
+		return nil, 0, err
+	}
+	kvPairs := make([]kvPair, len(pairs))
+	for idx, pair := range pairs {
+		kvPairs[idx] = kvPair{
+			key:   pair.Key,
+			value: pair.Value,
+		}
+	}
+	obj, err := kvPairsToObject(kvPairs, src.key, src.jsonValues)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := encodeJSON(obj)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Done
+	return data, newIndex, nil
+}
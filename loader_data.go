@@ -1,18 +1,45 @@
 package go_config_reader
 
 import (
+	"context"
+	"errors"
 	"strings"
 )
 
 // -----------------------------------------------------------------------------
 
-// loadFromData tries to load the content from a data url or tries to guess a JSON
-func loadFromData(source string) ([]byte, error) {
+// loadFromData tries to load the content from a data url or a raw JSON
+func loadFromData(ctx context.Context, source string) ([]byte, error) {
 	if strings.HasPrefix(source, "data://") {
-		return []byte(source[7:]), nil
+		source = source[7:]
+
+		// Check for an optional format hint like data://?format=yaml,content
+		if strings.HasPrefix(source, "?format=") {
+			idx := strings.IndexByte(source, ',')
+			if idx < 0 {
+				return nil, errors.New("invalid format hint")
+			}
+			format, err := parseFormat(source[8:idx])
+			if err != nil {
+				return nil, err
+			}
+			ReportFormat(ctx, format)
+
+			source = source[idx+1:]
+		}
+
+		return []byte(source), nil
+	}
+
+	if looksLikeJSON(source) {
+		return []byte(source), nil
 	}
 
-	// Try to guess a JSON
+	return nil, ErrWrongFormat
+}
+
+// looksLikeJSON tries to guess if the source is a JSON object or array
+func looksLikeJSON(source string) bool {
 	sourceLen := len(source)
 	idx := 0
 	for idx < sourceLen {
@@ -21,9 +48,5 @@ func loadFromData(source string) ([]byte, error) {
 		}
 		idx += 1
 	}
-	if idx < sourceLen && (source[idx] == '[' || source[idx] == '{') {
-		return []byte(source), nil
-	}
-
-	return nil, ErrWrongFormat
+	return idx < sourceLen && (source[idx] == '[' || source[idx] == '{')
 }
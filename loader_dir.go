@@ -0,0 +1,257 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// -----------------------------------------------------------------------------
+
+type dirLoader struct{}
+
+// dirSource holds the parameters of a directory source.
+type dirSource struct {
+	fsys      fs.FS // Filesystem set in the options if the directory is read from it, nil for the operating system's one
+	path      string
+	filesMode bool // Set if file names are mapped to keys instead of merging the files
+	trim      bool // Set if the trailing newline of the files must be removed in files mode
+}
+
+// dirState holds the information needed to detect changes in a loaded directory.
+type dirState struct {
+	src  *dirSource
+	hash [sha256.Size]byte
+}
+
+// -----------------------------------------------------------------------------
+
+// Load loads the content of the files in a directory.
+func (dirLoader) Load(ctx context.Context, source string) ([]byte, error) {
+	return loadFromDir(ctx, source)
+}
+
+// watch waits for changes in the loaded directory.
+func (dirLoader) watch(ctx context.Context, ls *loadedSource, notify func()) error {
+	state, ok := ls.state.(*dirState)
+	if !ok {
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = w.Close()
+	}()
+
+	// Check if the content really changed. Kubernetes mounts swap a symbolic link to a hidden directory, which
+	// also triggers events in the watched directory.
+	checkChanges := func() {
+		hash := [sha256.Size]byte{}
+		if data, err2 := readDir(ctx, state.src); err2 == nil {
+			hash = sha256.Sum256(data)
+		}
+		if !bytes.Equal(hash[:], state.hash[:]) {
+			state.hash = hash
+			notify()
+		}
+	}
+
+	err = w.Add(state.src.path)
+	if err != nil {
+		return err
+	}
+
+	// The directory may have changed since it was loaded
+	checkChanges()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case _, ok = <-w.Events:
+			if !ok {
+				return nil
+			}
+			checkChanges()
+
+		case err, ok = <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// loadFromDir tries to load the content from the files in a directory
+func loadFromDir(ctx context.Context, source string) ([]byte, error) {
+	src, err := parseDirSource(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readDir(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files are always converted to a json object
+	ReportFormat(ctx, FormatJSON)
+
+	// Keep track of the loaded directory in order to watch it. Directories in a custom filesystem are not watched.
+	if ls := getLoadedSource(ctx); ls != nil && src.fsys == nil {
+		ls.state = &dirState{
+			src:  src,
+			hash: sha256.Sum256(data),
+		}
+	}
+
+	// Done
+	return data, nil
+}
+
+func parseDirSource(ctx context.Context, source string) (*dirSource, error) {
+	src := dirSource{
+		fsys: getSourceFS(ctx, source),
+	}
+
+	if strings.HasPrefix(source, "dir://") {
+		source = source[6:]
+
+		// Parse query params
+		i := strings.Index(source, "?")
+		if i >= 0 {
+			queryMap, err := url.ParseQuery(source[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			source = source[:i]
+
+			for k, v := range queryMap {
+				if len(v) > 0 {
+					switch k {
+					case "mode":
+						switch v[0] {
+						case "merge":
+							src.filesMode = false
+						case "files":
+							src.filesMode = true
+						default:
+							return nil, errors.New("invalid directory mode")
+						}
+
+					case "trim":
+						src.trim, err = strconv.ParseBool(v[0])
+						if err != nil {
+							return nil, errors.New("invalid trim value")
+						}
+					}
+				}
+			}
+		}
+	} else if strings.HasPrefix(source, "embed://") {
+		source = source[8:]
+	} else {
+		source = strings.TrimPrefix(source, "file://")
+	}
+	if len(source) == 0 {
+		return nil, errors.New("invalid path")
+	}
+
+	// Convert path to absolute or to the form expected by the filesystem set in the options
+	var err error
+
+	src.path, err = resolvePath(src.fsys, source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Done
+	return &src, nil
+}
+
+// readDir reads the files in the directory in lexical order. Hidden files and subdirectories are ignored. In merge
+// mode, only json, yaml and toml files are read and merged. In files mode, an object with the file names as keys
+// and their contents as string values is returned.
+func readDir(ctx context.Context, src *dirSource) ([]byte, error) {
+	fsys := src.fsys
+	entries, err := readDirEntries(fsys, src.path)
+	if err != nil {
+		return nil, err
+	}
+
+	fragments := make([]fragment, 0)
+	files := make(map[string]interface{})
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		// Follow symbolic links like the ones created by Kubernetes mounts
+		filename := joinPath(fsys, src.path, name)
+		mode := entry.Type()
+		if mode&fs.ModeSymlink != 0 {
+			var info fs.FileInfo
+
+			info, err = statFile(fsys, filename)
+			if err != nil {
+				return nil, err
+			}
+			mode = info.Mode()
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+
+		if src.filesMode {
+			var data []byte
+
+			data, err = readFile(fsys, filename)
+			if err != nil {
+				return nil, err
+			}
+			if src.trim {
+				data = bytes.TrimSuffix(data, []byte("\n"))
+				data = bytes.TrimSuffix(data, []byte("\r"))
+			}
+			files[name] = string(data)
+		} else {
+			var data []byte
+
+			format := formatFromSource(name)
+			if format == FormatAuto {
+				continue
+			}
+
+			data, err = readFile(fsys, filename)
+			if err != nil {
+				return nil, err
+			}
+			fragments = append(fragments, fragment{
+				name:   name,
+				data:   data,
+				format: format,
+			})
+		}
+	}
+
+	if src.filesMode {
+		return encodeJSON(files)
+	}
+	if len(fragments) == 0 {
+		return nil, errors.New("no configuration files found in '" + src.path + "'")
+	}
+	return mergeFragments(ctx, fragments)
+}
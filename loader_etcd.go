@@ -0,0 +1,356 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	etcdDialTimeout = 5 * time.Second
+)
+
+// -----------------------------------------------------------------------------
+
+type etcdLoader struct{}
+
+// etcdSource holds the parameters of an etcd url.
+type etcdSource struct {
+	endpoints  []string
+	key        string
+	prefix     bool // Set if the key ends with a slash so all the keys below it are loaded
+	merge      bool // Set if the values of a prefix are documents that must be merged
+	jsonValues bool // Set if values of a prefix must be decoded as json instead of loaded as strings
+	username   string
+	password   string
+	useTLS     bool
+	caCert     string
+	clientCert string
+	clientKey  string
+	format     Format
+}
+
+// etcdState holds the information needed to detect changes in a loaded key or prefix.
+type etcdState struct {
+	src      *etcdSource
+	revision int64
+	hash     [sha256.Size]byte
+}
+
+// -----------------------------------------------------------------------------
+
+// errEtcdKeyNotFound is returned by readEtcd when the key or prefix does not exist
+var errEtcdKeyNotFound = errors.New("key not found")
+
+// -----------------------------------------------------------------------------
+
+// Load loads the value of a key or all the keys below a prefix.
+func (etcdLoader) Load(ctx context.Context, source string) ([]byte, error) {
+	return loadFromEtcd(ctx, source)
+}
+
+// watch uses an etcd watch in order to detect changes in the loaded key or prefix.
+func (etcdLoader) watch(ctx context.Context, ls *loadedSource, notify func()) error {
+	state, ok := ls.state.(*etcdState)
+	if !ok {
+		return nil
+	}
+
+	client, err := newEtcdClient(state.src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	opts := getReloadOptions(ctx)
+	failures := 0
+	for {
+		watchOpts := []clientv3.OpOption{
+			clientv3.WithRev(state.revision + 1),
+		}
+		if state.src.prefix {
+			watchOpts = append(watchOpts, clientv3.WithPrefix())
+		}
+
+		watchCtx, cancelWatch := context.WithCancel(clientv3.WithRequireLeader(ctx))
+		for resp := range client.Watch(watchCtx, state.src.key, watchOpts...) {
+			if resp.Err() != nil {
+				break
+			}
+			failures = 0
+			if len(resp.Events) > 0 {
+				state.revision = resp.Header.Revision
+				notify()
+			}
+		}
+		cancelWatch()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// The watch was interrupted, for example, because the revision was compacted. Wait and read the content
+		// again before watching from the new revision.
+		failures += 1
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pollDelay(opts, failures)):
+		}
+
+		data, revision, err2 := readEtcd(ctx, client, state.src)
+		if err2 != nil && err2 != errEtcdKeyNotFound {
+			continue
+		}
+		state.revision = revision
+		hash := sha256.Sum256(data)
+		if !bytes.Equal(hash[:], state.hash[:]) {
+			state.hash = hash
+			notify()
+		}
+	}
+}
+
+// loadFromEtcd tries to load the content from an etcd v3 cluster
+func loadFromEtcd(ctx context.Context, source string) ([]byte, error) {
+	src, err := parseEtcdSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newEtcdClient(src)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	data, revision, err := readEtcd(ctx, client, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefixes are always returned as a json object
+	if src.prefix {
+		ReportFormat(ctx, FormatJSON)
+	} else {
+		ReportFormat(ctx, src.format)
+	}
+
+	// Keep track of the revision in order to watch for changes
+	if ls := getLoadedSource(ctx); ls != nil {
+		ls.state = &etcdState{
+			src:      src,
+			revision: revision,
+			hash:     sha256.Sum256(data),
+		}
+	}
+
+	// Done
+	return data, nil
+}
+
+func parseEtcdSource(source string) (*etcdSource, error) {
+	src := etcdSource{}
+
+	if strings.HasPrefix(source, "etcd://") {
+		source = source[7:]
+	} else if strings.HasPrefix(source, "etcds://") {
+		source = source[8:]
+		src.useTLS = true
+	} else {
+		return nil, ErrWrongFormat
+	}
+
+	// Split endpoints, key and query params
+	i := strings.Index(source, "/")
+	if i <= 0 {
+		return nil, errors.New("invalid url")
+	}
+	endpoints := source[:i]
+	source = source[i+1:]
+
+	query := ""
+	i = strings.Index(source, "?")
+	if i >= 0 {
+		query = source[i+1:]
+		source = source[:i]
+	}
+
+	key, err := url.PathUnescape(source)
+	if err != nil {
+		return nil, errors.New("invalid key")
+	}
+	src.key = key
+	src.prefix = len(key) == 0 || strings.HasSuffix(key, "/")
+
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		if len(endpoint) == 0 {
+			return nil, errors.New("invalid endpoint")
+		}
+		src.endpoints = append(src.endpoints, endpoint)
+	}
+
+	// Parse query params
+	queryMap, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range queryMap {
+		if len(v) > 0 {
+			switch k {
+			case "username":
+				src.username = v[0]
+
+			case "password":
+				src.password = v[0]
+
+			case "ca_cert":
+				src.caCert = v[0]
+
+			case "client_cert":
+				src.clientCert = v[0]
+
+			case "client_key":
+				src.clientKey = v[0]
+
+			case "merge":
+				src.merge, err = strconv.ParseBool(v[0])
+				if err != nil {
+					return nil, errors.New("invalid merge value")
+				}
+
+			case "values":
+				switch v[0] {
+				case "string":
+					src.jsonValues = false
+				case "json":
+					src.jsonValues = true
+				default:
+					return nil, errors.New("invalid values mode")
+				}
+
+			case "format":
+				src.format, err = parseFormat(v[0])
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Done
+	return &src, nil
+}
+
+func newEtcdClient(src *etcdSource) (*clientv3.Client, error) {
+	config := clientv3.Config{
+		Endpoints:   src.endpoints,
+		DialTimeout: etcdDialTimeout,
+		Username:    src.username,
+		Password:    src.password,
+		Logger:      zap.NewNop(),
+	}
+
+	// Setup TLS if requested
+	if src.useTLS || len(src.caCert) > 0 || len(src.clientCert) > 0 {
+		tlsConfig := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+		if len(src.caCert) > 0 {
+			pem, err := ioutil.ReadFile(src.caCert)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, errors.New("invalid ca certificate")
+			}
+		}
+		if len(src.clientCert) > 0 || len(src.clientKey) > 0 {
+			cert, err := tls.LoadX509KeyPair(src.clientCert, src.clientKey)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		config.TLS = tlsConfig
+	}
+
+	return clientv3.New(config)
+}
+
+// readEtcd reads a key or a prefix and returns the content along with the revision of the store
+func readEtcd(ctx context.Context, client *clientv3.Client, src *etcdSource) ([]byte, int64, error) {
+	var opts []clientv3.OpOption
+
+	if src.prefix {
+		opts = append(opts, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	}
+
+	ctxWithTimeout, ctxCancel := context.WithTimeout(ctx, httpRequestTimeout)
+	defer ctxCancel()
+	resp, err := client.Get(ctxWithTimeout, src.key, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, resp.Header.Revision, errEtcdKeyNotFound
+	}
+
+	// Single keys are returned as is
+	if !src.prefix {
+		return resp.Kvs[0].Value, resp.Header.Revision, nil
+	}
+
+	var data []byte
+	if src.merge {
+		// Merge the documents stored in each key in lexical order
+		fragments := make([]fragment, len(resp.Kvs))
+		for idx, kv := range resp.Kvs {
+			fragments[idx] = fragment{
+				name:   string(kv.Key),
+				data:   kv.Value,
+				format: formatFromSource(string(kv.Key)),
+			}
+		}
+		data, err = mergeFragments(ctx, fragments)
+	} else {
+		// Convert the keys below the prefix into a json object
+		var obj map[string]interface{}
+
+		pairs := make([]kvPair, len(resp.Kvs))
+		for idx, kv := range resp.Kvs {
+			pairs[idx] = kvPair{
+				key:   string(kv.Key),
+				value: kv.Value,
+			}
+		}
+		obj, err = kvPairsToObject(pairs, src.key, src.jsonValues)
+		if err == nil {
+			data, err = encodeJSON(obj)
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Done
+	return data, resp.Header.Revision, nil
+}
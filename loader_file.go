@@ -1,39 +1,176 @@
 package go_config_reader
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 // -----------------------------------------------------------------------------
 
-// loadFromFile tries to load the content from a file
-func loadFromFile(_ context.Context, source string) ([]byte, error) {
-	// NOTE: We are not making use of the context assuming configuration files will be small and on a local disk
-	var err error
+type fileLoader struct{}
+
+// fileState holds the information needed to detect changes in a loaded file.
+type fileState struct {
+	path string
+	hash [sha256.Size]byte
+}
+
+// -----------------------------------------------------------------------------
+
+// Load loads the content of a file. Paths ending with a slash are loaded as directories and the files matching
+// patterns like conf.d/*.json are merged. Paths containing pattern characters are taken literally if such a file
+// exists, so names like conf[prod].json can still be loaded.
+func (fileLoader) Load(ctx context.Context, source string) ([]byte, error) {
+	if strings.HasSuffix(source, "/") || strings.HasSuffix(source, string(filepath.Separator)) {
+		return loadFromDir(ctx, source)
+	}
+	if hasGlobMeta(source) && !isExistingFile(ctx, source) {
+		return loadFromGlob(ctx, source)
+	}
+	return loadFromFile(ctx, source)
+}
+
+// watch waits for changes in the loaded file. It monitors the parent directory, instead of the file itself, in
+// order to detect editors that replace the file and symbolic link swaps like the ones done in Kubernetes configmaps.
+func (fileLoader) watch(ctx context.Context, ls *loadedSource, notify func()) error {
+	if _, ok := ls.state.(*dirState); ok {
+		return dirLoader{}.watch(ctx, ls, notify)
+	}
+	if state, ok := ls.state.(*globState); ok {
+		return watchGlob(ctx, state, notify)
+	}
+
+	state, ok := ls.state.(*fileState)
+	if !ok {
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = w.Close()
+	}()
+
+	watchedDirs := make(map[string]struct{})
+	addDirs := func() error {
+		dirs := []string{filepath.Dir(state.path)}
+		if resolvedPath, err2 := filepath.EvalSymlinks(state.path); err2 == nil {
+			dirs = append(dirs, filepath.Dir(resolvedPath))
+		}
+		for _, dir := range dirs {
+			if _, found := watchedDirs[dir]; !found {
+				if err2 := w.Add(dir); err2 != nil {
+					return err2
+				}
+				watchedDirs[dir] = struct{}{}
+			}
+		}
+		return nil
+	}
+
+	// Check if the content really changed. A missing file is considered as a change because the editor may be
+	// in the middle of an atomic save and the debounced reload will tell.
+	checkChanges := func() {
+		hash := [sha256.Size]byte{}
+		if data, err2 := ioutil.ReadFile(state.path); err2 == nil {
+			hash = sha256.Sum256(data)
+		}
+		if !bytes.Equal(hash[:], state.hash[:]) {
+			state.hash = hash
+			notify()
+		}
+	}
+
+	err = addDirs()
+	if err != nil {
+		return err
+	}
+
+	// The file may have changed since it was loaded
+	checkChanges()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case _, ok = <-w.Events:
+			if !ok {
+				return nil
+			}
 
-	source = strings.TrimPrefix(source, "file://")
+			// The target of a symbolic link may have been moved to another directory
+			_ = addDirs()
 
-	// Convert path to absolute
-	if !filepath.IsAbs(source) {
-		var currentPath string
+			checkChanges()
 
-		currentPath, err = os.Getwd()
-		if err != nil {
-			return nil, err
+		case err, ok = <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
 		}
-		source = filepath.Join(currentPath, source)
 	}
+}
+
+// isExistingFile returns true if the source names an existing file
+func isExistingFile(ctx context.Context, source string) bool {
+	fsys := getSourceFS(ctx, source)
+	if strings.HasPrefix(source, "embed://") {
+		source = source[8:]
+	} else {
+		source = strings.TrimPrefix(source, "file://")
+	}
+
+	source, err := resolvePath(fsys, source)
+	if err != nil {
+		return false
+	}
+	info, err := statFile(fsys, source)
+	return err == nil && !info.IsDir()
+}
 
-	// Normalize path
-	source, err = filepath.Abs(source)
+// loadFromFile tries to load the content from a file
+func loadFromFile(ctx context.Context, source string) ([]byte, error) {
+	// NOTE: We are not making use of the context assuming configuration files will be small and on a local disk
+	fsys := getSourceFS(ctx, source)
+	if strings.HasPrefix(source, "embed://") {
+		source = source[8:]
+	} else {
+		source = strings.TrimPrefix(source, "file://")
+	}
+
+	// Convert path to absolute or to the form expected by the filesystem set in the options
+	source, err := resolvePath(fsys, source)
 	if err != nil {
 		return nil, err
 	}
 
+	// The file extension determines the format
+	ReportFormat(ctx, formatFromSource(source))
+
 	// Load file
-	return ioutil.ReadFile(source)
+	data, err := readFile(fsys, source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep track of the loaded file in order to watch it. Files in a custom filesystem are not watched.
+	if ls := getLoadedSource(ctx); ls != nil && fsys == nil {
+		ls.state = &fileState{
+			path: source,
+			hash: sha256.Sum256(data),
+		}
+	}
+
+	// Done
+	return data, nil
 }
@@ -0,0 +1,174 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// -----------------------------------------------------------------------------
+
+// globState holds the information needed to detect changes in the files matching a pattern.
+type globState struct {
+	pattern string
+	hash    [sha256.Size]byte
+}
+
+// -----------------------------------------------------------------------------
+
+// hasGlobMeta returns true if the path contains any of the special characters used by file patterns
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// loadFromGlob loads all the files matching the pattern and deep merges them in lexical order
+func loadFromGlob(ctx context.Context, pattern string) ([]byte, error) {
+	fsys := getSourceFS(ctx, pattern)
+	if strings.HasPrefix(pattern, "embed://") {
+		pattern = pattern[8:]
+	} else {
+		pattern = strings.TrimPrefix(pattern, "file://")
+	}
+
+	pattern, err := resolvePath(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readGlob(ctx, fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files are always merged into a json document
+	ReportFormat(ctx, FormatJSON)
+
+	// Keep track of the pattern in order to watch the matching files. Files in a custom filesystem are not watched.
+	if ls := getLoadedSource(ctx); ls != nil && fsys == nil {
+		ls.state = &globState{
+			pattern: pattern,
+			hash:    sha256.Sum256(data),
+		}
+	}
+
+	// Done
+	return data, nil
+}
+
+// readGlob reads and merges the files matching the pattern in the given filesystem or in the operating system's one
+// if nil
+func readGlob(ctx context.Context, fsys fs.FS, pattern string) ([]byte, error) {
+	matches, err := globFiles(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	if len(matches) == 0 {
+		if s := getSession(ctx); s != nil && s.options.AllowEmptyGlob {
+			return []byte("{}"), nil
+		}
+		return nil, errors.New("no files match '" + pattern + "'")
+	}
+
+	fragments := make([]fragment, len(matches))
+	for idx, filename := range matches {
+		var data []byte
+
+		data, err = readFile(fsys, filename)
+		if err != nil {
+			return nil, err
+		}
+		fragments[idx] = fragment{
+			name:   filename,
+			data:   data,
+			format: formatFromSource(filename),
+		}
+	}
+
+	return mergeFragments(ctx, fragments)
+}
+
+// watchGlob waits for changes in the files matching a pattern. It monitors the directories containing the matching
+// files and the pattern's directory, if it is not a pattern itself, in order to detect new files.
+func watchGlob(ctx context.Context, state *globState, notify func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = w.Close()
+	}()
+
+	watchedDirs := make(map[string]struct{})
+	addDirs := func() error {
+		dirs := make([]string, 0)
+		if patternDir := filepath.Dir(state.pattern); !hasGlobMeta(patternDir) {
+			dirs = append(dirs, patternDir)
+		}
+		if matches, err2 := filepath.Glob(state.pattern); err2 == nil {
+			for _, filename := range matches {
+				dirs = append(dirs, filepath.Dir(filename))
+			}
+		}
+		for _, dir := range dirs {
+			if _, found := watchedDirs[dir]; !found {
+				if err2 := w.Add(dir); err2 != nil {
+					return err2
+				}
+				watchedDirs[dir] = struct{}{}
+			}
+		}
+		return nil
+	}
+
+	// Check if the merged content really changed
+	checkChanges := func() {
+		hash := [sha256.Size]byte{}
+		if data, err2 := readGlob(ctx, nil, state.pattern); err2 == nil {
+			hash = sha256.Sum256(data)
+		}
+		if !bytes.Equal(hash[:], state.hash[:]) {
+			state.hash = hash
+			notify()
+		}
+	}
+
+	err = addDirs()
+	if err != nil {
+		return err
+	}
+
+	// The files may have changed since they were loaded
+	checkChanges()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case _, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+
+			// New files may be in other directories
+			_ = addDirs()
+
+			checkChanges()
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		}
+	}
+}
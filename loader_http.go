@@ -1,11 +1,14 @@
 package go_config_reader
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,46 +19,137 @@ const (
 
 // -----------------------------------------------------------------------------
 
+type httpLoader struct{}
+
+// httpState holds the information needed to detect changes in a loaded web resource.
+type httpState struct {
+	etag         string
+	lastModified string
+	hash         [sha256.Size]byte
+}
+
+// -----------------------------------------------------------------------------
+
+var httpClient *http.Client
+var httpClientOnce sync.Once
+
+// -----------------------------------------------------------------------------
+
+// Load loads the content of a web url.
+func (httpLoader) Load(ctx context.Context, source string) ([]byte, error) {
+	return loadFromHttp(ctx, source)
+}
+
+// watch periodically polls the web url using conditional requests in order to detect changes.
+func (httpLoader) watch(ctx context.Context, ls *loadedSource, notify func()) error {
+	state, ok := ls.state.(*httpState)
+	if !ok {
+		return nil
+	}
+
+	return poll(ctx, func() error {
+		header := http.Header{}
+		if len(state.etag) > 0 {
+			header.Set("If-None-Match", state.etag)
+		}
+		if len(state.lastModified) > 0 {
+			header.Set("If-Modified-Since", state.lastModified)
+		}
+
+		resp, body, err := httpGet(ctx, ls.source, header)
+		if err != nil {
+			return err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusNotModified:
+			return nil
+
+		case http.StatusOK:
+			state.etag = resp.Header.Get("ETag")
+			state.lastModified = resp.Header.Get("Last-Modified")
+
+			// Servers not supporting conditional requests will always return the content
+			hash := sha256.Sum256(body)
+			if !bytes.Equal(hash[:], state.hash[:]) {
+				state.hash = hash
+				notify()
+			}
+			return nil
+		}
+
+		return fmt.Errorf("unexpected HTTP status code [http-status=%v]", resp.Status)
+	})
+}
+
 // loadFromHttp tries to load the content from a web url
 func loadFromHttp(ctx context.Context, source string) ([]byte, error) {
-	var resp *http.Response
-
 	if !(strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")) {
 		return nil, ErrWrongFormat
 	}
 
-	// Create custom http transport
-	// From: https://www.loginradius.com/blog/async/tune-the-go-http-client-for-high-performance/
-	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
-	httpTransport.MaxIdleConns = 10
-	httpTransport.MaxConnsPerHost = 10
-	httpTransport.IdleConnTimeout = 60 * time.Second
-	httpTransport.MaxIdleConnsPerHost = 10
-	httpTransport.ResponseHeaderTimeout = httpResponseHeadersTimeout
+	resp, responseBody, err := httpGet(ctx, source, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	// Prepare request
-	client := http.Client{
-		Transport: httpTransport,
+	// Check if the request succeeded
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected HTTP status code [http-status=%v]", resp.Status)
+	}
+
+	// Get the format from the content type or, if not significant, from the url extension
+	format := formatFromContentType(resp.Header.Get("Content-Type"))
+	if format == FormatAuto {
+		format = formatFromSource(source)
+	}
+	ReportFormat(ctx, format)
+
+	// Keep track of the validators in order to watch for changes
+	if ls := getLoadedSource(ctx); ls != nil {
+		ls.state = &httpState{
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+			hash:         sha256.Sum256(responseBody),
+		}
 	}
 
+	// Done
+	return responseBody, nil
+}
+
+// httpGet executes a GET request and returns the response along with its body
+func httpGet(ctx context.Context, source string, header http.Header) (*http.Response, []byte, error) {
+	httpClientOnce.Do(func() {
+		// Create custom http transport
+		// From: https://www.loginradius.com/blog/async/tune-the-go-http-client-for-high-performance/
+		httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+		httpTransport.MaxIdleConns = 10
+		httpTransport.MaxConnsPerHost = 10
+		httpTransport.IdleConnTimeout = 60 * time.Second
+		httpTransport.MaxIdleConnsPerHost = 10
+		httpTransport.ResponseHeaderTimeout = httpResponseHeadersTimeout
+
+		httpClient = &http.Client{
+			Transport: httpTransport,
+		}
+	})
+
+	// Prepare request
 	req, err := http.NewRequest("GET", source, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
 	}
 
 	// Execute request
 	ctxWithTimeout, ctxCancel := context.WithTimeout(ctx, httpRequestTimeout)
 	defer ctxCancel()
-	resp, err = client.Do(req.WithContext(ctxWithTimeout))
+	resp, err := httpClient.Do(req.WithContext(ctxWithTimeout))
 	if err != nil {
-		return nil, err
-	}
-
-	// Check if the request succeeded
-	if resp.StatusCode != 200 {
-		_ = resp.Body.Close()
-
-		return nil, fmt.Errorf("unexpected HTTP status code [http-status=%v]", resp.Status)
+		return nil, nil, err
 	}
 
 	// Read response body
@@ -63,9 +157,9 @@ func loadFromHttp(ctx context.Context, source string) ([]byte, error) {
 	responseBody, err = ioutil.ReadAll(resp.Body)
 	_ = resp.Body.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Done
-	return responseBody, nil
+	return resp, responseBody, nil
 }
@@ -0,0 +1,254 @@
+package go_config_reader
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultS3Region = "us-east-1"
+)
+
+// -----------------------------------------------------------------------------
+
+type s3Loader struct{}
+
+// s3Source holds the parameters of an s3 url.
+type s3Source struct {
+	bucket       string
+	key          string
+	versionID    string // Version of the object to read
+	region       string
+	endpoint     string // Custom endpoint for S3-compatible services like MinIO
+	pathStyle    bool
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	profile      string
+	format       Format
+}
+
+// s3State holds the information needed to detect changes in a loaded object.
+type s3State struct {
+	src       *s3Source
+	etag      string
+	versionID string
+}
+
+// -----------------------------------------------------------------------------
+
+// Load loads the content of an object.
+func (s3Loader) Load(ctx context.Context, source string) ([]byte, error) {
+	return loadFromS3(ctx, source)
+}
+
+// watch periodically checks the object's ETag and version in order to detect changes.
+func (s3Loader) watch(ctx context.Context, ls *loadedSource, notify func()) error {
+	state, ok := ls.state.(*s3State)
+	if !ok || len(state.src.versionID) > 0 {
+		// Pinned versions never change
+		return nil
+	}
+
+	client, err := newS3Client(ctx, state.src)
+	if err != nil {
+		return err
+	}
+
+	return poll(ctx, func() error {
+		output, err2 := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(state.src.bucket),
+			Key:    aws.String(state.src.key),
+		})
+		if err2 != nil {
+			return err2
+		}
+
+		etag := aws.ToString(output.ETag)
+		versionID := aws.ToString(output.VersionId)
+		if etag != state.etag || versionID != state.versionID {
+			state.etag = etag
+			state.versionID = versionID
+			notify()
+		}
+		return nil
+	})
+}
+
+// loadFromS3 tries to load the content from an S3-compatible object storage
+func loadFromS3(ctx context.Context, source string) ([]byte, error) {
+	src, err := parseS3Source(source)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newS3Client(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read object
+	input := s3.GetObjectInput{
+		Bucket: aws.String(src.bucket),
+		Key:    aws.String(src.key),
+	}
+	if len(src.versionID) > 0 {
+		input.VersionId = aws.String(src.versionID)
+	}
+	output, err := client.GetObject(ctx, &input)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(output.Body)
+	_ = output.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the format from the hint, the content type or, if not significant, from the key extension
+	format := src.format
+	if format == FormatAuto {
+		format = formatFromContentType(aws.ToString(output.ContentType))
+	}
+	if format == FormatAuto {
+		format = formatFromSource(src.key)
+	}
+	ReportFormat(ctx, format)
+
+	// Keep track of the ETag and version in order to watch for changes
+	if ls := getLoadedSource(ctx); ls != nil {
+		ls.state = &s3State{
+			src:       src,
+			etag:      aws.ToString(output.ETag),
+			versionID: aws.ToString(output.VersionId),
+		}
+		ls.metadata = map[string]string{
+			"etag": aws.ToString(output.ETag),
+		}
+		if output.VersionId != nil {
+			ls.metadata["version_id"] = aws.ToString(output.VersionId)
+		}
+	}
+
+	// Done
+	return data, nil
+}
+
+func parseS3Source(source string) (*s3Source, error) {
+	if !strings.HasPrefix(source, "s3://") {
+		return nil, ErrWrongFormat
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	src := s3Source{
+		bucket: u.Host,
+		key:    strings.TrimPrefix(u.Path, "/"),
+	}
+	if len(src.bucket) == 0 {
+		return nil, errors.New("invalid bucket")
+	}
+	if len(src.key) == 0 {
+		return nil, errors.New("invalid key")
+	}
+
+	// Parse query params
+	for k, v := range u.Query() {
+		if len(v) > 0 {
+			switch k {
+			case "version_id":
+				src.versionID = v[0]
+
+			case "region":
+				src.region = v[0]
+
+			case "endpoint":
+				src.endpoint = v[0]
+
+			case "path_style":
+				src.pathStyle, err = strconv.ParseBool(v[0])
+				if err != nil {
+					return nil, errors.New("invalid path_style value")
+				}
+
+			case "access_key":
+				src.accessKey = v[0]
+
+			case "secret_key":
+				src.secretKey = v[0]
+
+			case "session_token":
+				src.sessionToken = v[0]
+
+			case "profile":
+				src.profile = v[0]
+
+			case "format":
+				src.format, err = parseFormat(v[0])
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Keys must be set in pairs
+	if (len(src.accessKey) > 0) != (len(src.secretKey) > 0) {
+		return nil, errors.New("access and secret keys must be set together")
+	}
+
+	// Done
+	return &src, nil
+}
+
+// newS3Client creates a client using explicit keys, if provided, or the standard credential chain which includes
+// environment variables, shared configuration files and instance roles.
+func newS3Client(ctx context.Context, src *s3Source) (*s3.Client, error) {
+	var loadOpts []func(*config.LoadOptions) error
+
+	if len(src.region) > 0 {
+		loadOpts = append(loadOpts, config.WithRegion(src.region))
+	}
+	if len(src.profile) > 0 {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(src.profile))
+	}
+	if len(src.accessKey) > 0 {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(src.accessKey, src.secretKey, src.sessionToken),
+		))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Region) == 0 {
+		cfg.Region = defaultS3Region
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if len(src.endpoint) > 0 {
+			o.EndpointResolver = s3.EndpointResolverFromURL(src.endpoint, func(e *aws.Endpoint) {
+				e.HostnameImmutable = src.pathStyle
+			})
+		}
+		o.UsePathStyle = src.pathStyle
+	})
+
+	// Done
+	return client, nil
+}
@@ -0,0 +1,76 @@
+package go_config_reader
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// -----------------------------------------------------------------------------
+
+// stdinContent holds the standard input once it is read. The standard input can be consumed only once, so the
+// same content is returned to all the load operations, like the reloads done by Watch.
+type stdinContent struct {
+	once sync.Once
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// -----------------------------------------------------------------------------
+
+var stdin = stdinContent{
+	done: make(chan struct{}),
+}
+
+// -----------------------------------------------------------------------------
+
+// loadFromStdin tries to load the content from the standard input
+func loadFromStdin(ctx context.Context, source string) ([]byte, error) {
+	if source != "-" {
+		if !strings.HasPrefix(source, "stdin://") {
+			return nil, ErrWrongFormat
+		}
+		source = source[8:]
+
+		// Only an optional format hint like stdin://?format=yaml is accepted
+		if len(source) > 0 {
+			if source[0] != '?' {
+				return nil, errors.New("invalid stdin url")
+			}
+			query, err := url.ParseQuery(source[1:])
+			if err != nil {
+				return nil, errors.New("invalid stdin url")
+			}
+			format, err := parseFormat(query.Get("format"))
+			if err != nil {
+				return nil, err
+			}
+			ReportFormat(ctx, format)
+		}
+	}
+
+	// Start reading in the background so the wait can be canceled
+	stdin.once.Do(func() {
+		go func() {
+			stdin.data, stdin.err = ioutil.ReadAll(os.Stdin)
+			close(stdin.done)
+		}()
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-stdin.done:
+	}
+	if stdin.err != nil {
+		return nil, stdin.err
+	}
+
+	// Return a copy because the caller may modify it
+	return append([]byte{}, stdin.data...), nil
+}
@@ -3,22 +3,335 @@ package go_config_reader
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 )
 
 // -----------------------------------------------------------------------------
 
+// VaultOptions indicates the settings used to access Hashicorp Vault servers. Parameters present in a vault url have
+// preference over these.
+type VaultOptions struct {
+	// Address of the server used by the ${VTRANSIT:...} macros, like "https://vault.example.com:8200". If not set,
+	// the VAULT_ADDR environment variable is used.
+	Address string
+
+	// Path where the Transit secrets engine used by the ${VTRANSIT:...} macros is mounted. Defaults to "transit".
+	TransitMount string
+
+	// Access token to use. If no token nor authentication method is set, the token is read from the
+	// VAULT_TOKEN environment variable.
+	Token string
+
+	// Path to a file containing the access token.
+	TokenFile string
+
+	// Authentication method to use in order to obtain a token. Can be "approle", "kubernetes" or "userpass".
+	AuthMethod string
+
+	// Path where the authentication method is mounted. Defaults to the method name.
+	AuthMount string
+
+	// Credentials for the AppRole authentication method. SecretIDFile can be used instead of SecretID.
+	RoleID       string
+	SecretID     string
+	SecretIDFile string
+
+	// Role and service account token file for the Kubernetes authentication method. JWTFile defaults to
+	// /var/run/secrets/kubernetes.io/serviceaccount/token.
+	Role    string
+	JWTFile string
+
+	// Credentials for the userpass authentication method.
+	Username string
+	Password string
+
+	// Vault Enterprise namespace.
+	Namespace string
+
+	// Paths to the PEM-encoded CA certificate file or directory used to verify the server certificate.
+	CACert string
+	CAPath string
+
+	// Paths to the PEM-encoded client certificate and private key used for mutual TLS authentication.
+	ClientCert string
+	ClientKey  string
+
+	// Name used to verify the server certificate and to set the SNI host.
+	TLSServerName string
+
+	// Disables the server certificate verification. Do not use in production.
+	TLSSkipVerify bool
+
+	// Number of times a failed request is retried. Defaults to 2. Set a negative value to disable retries.
+	MaxRetries int
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultKubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultVaultMaxRetries   = 2
+	vaultRevokeTimeout       = 10 * time.Second
+)
+
+// -----------------------------------------------------------------------------
+
+type vaultLoader struct{}
+
+// vaultSource holds the parameters of a vault url.
+type vaultSource struct {
+	address string
+	params  VaultOptions // Settings specified in the url
+	hasAuth bool         // Set if the url selects the authentication method
+	opts    VaultOptions // Settings in use after merging the url ones with the options
+	token   vaultToken
+	path    string
+	version string // Version of a KV v2 secret to read
+	keys    []string
+	format  Format
+
+	// Set once the mount is detected
+	readPath  string
+	kvVersion int
+}
+
+// vaultToken holds an access token and, if it was obtained through an authentication method, when it expires.
+type vaultToken struct {
+	value     string
+	ttl       time.Duration
+	expiresAt time.Time // Zero if the token does not expire or it was not obtained by logging in
+}
+
+// vaultMount holds information about a secrets engine mount.
+type vaultMount struct {
+	path      string
+	kvVersion int // Zero if the mount is not a KV engine or the version is unknown
+}
+
+// vaultState holds the information needed to detect changes in a loaded secret.
+type vaultState struct {
+	src            *vaultSource
+	version        int64
+	hash           [sha256.Size]byte
+	leaseID        string
+	leaseDuration  time.Duration // Remaining duration of the lease since the last read or renewal
+	leaseIncrement time.Duration // Duration requested on each renewal
+	renewable      bool
+}
+
+// -----------------------------------------------------------------------------
+
+// Load loads the content of a secret.
+func (vaultLoader) Load(ctx context.Context, source string) ([]byte, error) {
+	return loadFromVault(ctx, source)
+}
+
+// watch detects changes in the loaded secret. Secrets with a lease, like dynamic ones, are renewed before the lease
+// expires and considered changed when renewal is no longer possible so new ones are issued. The rest are read
+// periodically and compared against the loaded version.
+func (vaultLoader) watch(ctx context.Context, ls *loadedSource, notify func()) error {
+	var refreshAt time.Time
+
+	state, ok := ls.state.(*vaultState)
+	if !ok {
+		return nil
+	}
+
+	client, err := newVaultClient(state.src)
+	if err != nil {
+		return err
+	}
+
+	opts := getReloadOptions(ctx)
+	failures := 0
+	for {
+		// Refresh the secret before the lease expires
+		delay := pollDelay(opts, failures)
+		if state.leaseDuration > 0 {
+			if refreshAt.IsZero() {
+				refreshAt = time.Now().Add(state.leaseDuration * 2 / 3)
+			}
+			if untilRefresh := time.Until(refreshAt); untilRefresh < delay {
+				delay = untilRefresh
+			}
+		}
+		if !state.src.token.expiresAt.IsZero() {
+			if untilRefresh := time.Until(state.src.token.expiresAt.Add(-state.src.token.ttl / 3)); untilRefresh < delay {
+				delay = untilRefresh
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		// Login again if the token obtained through an authentication method is about to expire
+		err = refreshVaultToken(ctx, client, state.src)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures += 1
+			continue
+		}
+
+		// Secrets with a lease cannot be read again without issuing a new one, so try to renew the lease
+		if len(state.leaseID) > 0 {
+			if time.Now().Before(refreshAt) {
+				continue
+			}
+			refreshAt = time.Time{}
+
+			if state.renewable {
+				err = renewVaultLease(ctx, client, state)
+				if err == nil {
+					continue
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+			}
+
+			// Renewal is not possible, so reload the configuration to get a new secret
+			notify()
+			continue
+		}
+
+		var secret *api.Secret
+		var data []byte
+
+		secret, err = readVaultSecret(ctx, client, state.src)
+		if err == nil {
+			data, _, err = extractVaultData(secret, state.src)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures += 1
+			continue
+		}
+		failures = 0
+
+		version := getVaultSecretVersion(secret, state.src)
+		hash := sha256.Sum256(data)
+		state.leaseDuration = time.Duration(secret.LeaseDuration) * time.Second
+		refreshAt = time.Time{}
+		if version != state.version || !bytes.Equal(hash[:], state.hash[:]) {
+			state.version = version
+			state.hash = hash
+			notify()
+		}
+	}
+}
+
+// release revokes the lease of the loaded secret, if any, so dynamic credentials do not outlive the watcher.
+func (vaultLoader) release(ls *loadedSource) {
+	state, ok := ls.state.(*vaultState)
+	if !ok || len(state.leaseID) == 0 {
+		return
+	}
+
+	client, err := newVaultClient(state.src)
+	if err != nil {
+		return
+	}
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), vaultRevokeTimeout)
+	defer cancelCtx()
+
+	_ = client.Sys().RevokeWithContext(ctx, state.leaseID)
+}
+
 // loadFromVault tries to load the content from Hashicorp Vault
 func loadFromVault(ctx context.Context, source string) ([]byte, error) {
-	var client *api.Client
-	var secret *api.Secret
-	var buf bytes.Buffer
+	src, err := parseVaultSource(source)
+	if err != nil {
+		return nil, err
+	}
+	resolveVaultOptions(ctx, src)
+
+	// Create accessor
+	client, err := newVaultClient(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get an access token
+	err = vaultAuthenticate(ctx, client, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read secret
+	secret, err := readVaultSecret(ctx, client, src)
+	if err != nil {
+		return nil, err
+	}
+
+	data, isString, err := extractVaultData(secret, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// String values are returned as is, else they are encoded as json
+	if isString {
+		ReportFormat(ctx, src.format)
+	} else {
+		ReportFormat(ctx, FormatJSON)
+	}
+
+	// Keep track of the secret version and lease in order to watch for changes
+	if ls := getLoadedSource(ctx); ls != nil {
+		ls.state = &vaultState{
+			src:            src,
+			version:        getVaultSecretVersion(secret, src),
+			hash:           sha256.Sum256(data),
+			leaseID:        secret.LeaseID,
+			leaseDuration:  time.Duration(secret.LeaseDuration) * time.Second,
+			leaseIncrement: time.Duration(secret.LeaseDuration) * time.Second,
+			renewable:      secret.Renewable,
+		}
+	}
+
+	// Done
+	return data, nil
+}
+
+// renewVaultLease extends the lease of a secret. If the lease reached its maximum time to live, it is marked as not
+// renewable so a new secret is issued before it expires.
+func renewVaultLease(ctx context.Context, client *api.Client, state *vaultState) error {
+	secret, err := client.Sys().RenewWithContext(ctx, state.leaseID, int(state.leaseIncrement/time.Second))
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.LeaseDuration <= 0 {
+		return errors.New("unable to renew lease")
+	}
+
+	state.leaseDuration = time.Duration(secret.LeaseDuration) * time.Second
+	if !secret.Renewable || state.leaseDuration < state.leaseIncrement {
+		state.renewable = false
+	}
+
+	// Done
+	return nil
+}
 
+func parseVaultSource(source string) (*vaultSource, error) {
 	if !(strings.HasPrefix(source, "vault://") || strings.HasPrefix(source, "vaults://")) {
 		return nil, ErrWrongFormat
 	}
@@ -31,7 +344,10 @@ func loadFromVault(ctx context.Context, source string) ([]byte, error) {
 		return nil, errors.New("invalid url")
 	}
 	query := source[(i + 1):]
-	source = source[:i]
+	src := vaultSource{
+		address: source[:i],
+		keys:    make([]string, 0),
+	}
 
 	// Remove fragment
 	i = strings.Index(query, "#")
@@ -46,27 +362,95 @@ func loadFromVault(ctx context.Context, source string) ([]byte, error) {
 	}
 
 	// Extract required parameters
-	token := ""
-	path := ""
-	keys := make([]string, 0)
 	for k, v := range queryMap {
 		if len(v) > 0 {
 			switch k {
 			case "token":
 				// Set token
-				token = v[0]
+				src.params.Token = v[0]
+				src.hasAuth = true
+
+			case "token_file":
+				src.params.TokenFile = v[0]
+				src.hasAuth = true
+
+			case "auth":
+				src.params.AuthMethod = v[0]
+				src.hasAuth = true
+
+			case "auth_mount":
+				src.params.AuthMount = v[0]
+
+			case "role_id":
+				src.params.RoleID = v[0]
+
+			case "secret_id":
+				src.params.SecretID = v[0]
+
+			case "secret_id_file":
+				src.params.SecretIDFile = v[0]
+
+			case "role":
+				src.params.Role = v[0]
+
+			case "jwt_file":
+				src.params.JWTFile = v[0]
+
+			case "username":
+				src.params.Username = v[0]
+
+			case "password":
+				src.params.Password = v[0]
 
 			case "path":
 				// Set path
-				path = v[0]
+				src.path = strings.TrimPrefix(v[0], "/")
+
+			case "version":
+				// Set secret version
+				if _, err = strconv.ParseUint(v[0], 10, 32); err != nil {
+					return nil, errors.New("invalid version")
+				}
+				src.version = v[0]
+
+			case "namespace":
+				src.params.Namespace = v[0]
+
+			case "ca_cert":
+				src.params.CACert = v[0]
+
+			case "ca_path":
+				src.params.CAPath = v[0]
+
+			case "client_cert":
+				src.params.ClientCert = v[0]
+
+			case "client_key":
+				src.params.ClientKey = v[0]
+
+			case "tls_server_name":
+				src.params.TLSServerName = v[0]
+
+			case "tls_skip_verify":
+				src.params.TLSSkipVerify, err = strconv.ParseBool(v[0])
+				if err != nil {
+					return nil, errors.New("invalid tls_skip_verify value")
+				}
+
+			case "format":
+				// Set format hint
+				src.format, err = parseFormat(v[0])
+				if err != nil {
+					return nil, err
+				}
 
 			case "key":
 				// Set and validate key
-				keys = strings.SplitN(v[0], "/", -1)
-				if len(keys) == 0 {
+				src.keys = strings.SplitN(v[0], "/", -1)
+				if len(src.keys) == 0 {
 					return nil, errors.New("invalid key")
 				}
-				for _, key := range keys {
+				for _, key := range src.keys {
 					if len(key) == 0 {
 						return nil, errors.New("invalid key")
 					}
@@ -75,27 +459,327 @@ func loadFromVault(ctx context.Context, source string) ([]byte, error) {
 		}
 	}
 
-	// Check access token
-	if len(token) == 0 {
-		return nil, errors.New("missing access token")
-	}
-
 	// Check path
-	if len(path) == 0 {
+	if len(src.path) == 0 {
 		return nil, errors.New("invalid path")
 	}
 
-	// Create accessor
-	client, err = api.NewClient(&api.Config{
-		Address: source,
-	})
+	// Done
+	return &src, nil
+}
+
+// resolveVaultOptions merges the settings specified in the url with the ones in the options. The former ones have
+// preference.
+func resolveVaultOptions(ctx context.Context, src *vaultSource) {
+	if s := getSession(ctx); s != nil {
+		src.opts = s.options.Vault
+	}
+
+	if src.hasAuth {
+		src.opts.Token = src.params.Token
+		src.opts.TokenFile = src.params.TokenFile
+		src.opts.AuthMethod = src.params.AuthMethod
+	}
+	overrideString(&src.opts.AuthMount, src.params.AuthMount)
+	overrideString(&src.opts.RoleID, src.params.RoleID)
+	overrideString(&src.opts.SecretID, src.params.SecretID)
+	overrideString(&src.opts.SecretIDFile, src.params.SecretIDFile)
+	overrideString(&src.opts.Role, src.params.Role)
+	overrideString(&src.opts.JWTFile, src.params.JWTFile)
+	overrideString(&src.opts.Username, src.params.Username)
+	overrideString(&src.opts.Password, src.params.Password)
+
+	overrideString(&src.opts.Namespace, src.params.Namespace)
+	overrideString(&src.opts.CACert, src.params.CACert)
+	overrideString(&src.opts.CAPath, src.params.CAPath)
+	overrideString(&src.opts.ClientCert, src.params.ClientCert)
+	overrideString(&src.opts.ClientKey, src.params.ClientKey)
+	overrideString(&src.opts.TLSServerName, src.params.TLSServerName)
+	if src.params.TLSSkipVerify {
+		src.opts.TLSSkipVerify = true
+	}
+}
+
+// vaultAuthenticate sets the access token of the client. Tokens obtained through an authentication method are
+// cached in the session so later ${SRC:vault://...} macros in the same load operation reuse them.
+func vaultAuthenticate(ctx context.Context, client *api.Client, src *vaultSource) error {
+	s := getSession(ctx)
+
+	auth := src.opts
+
+	// Plain tokens
+	switch {
+	case len(auth.Token) > 0:
+		src.token = vaultToken{
+			value: auth.Token,
+		}
+
+	case len(auth.TokenFile) > 0:
+		token, err := readSecretFile(auth.TokenFile)
+		if err != nil {
+			return err
+		}
+		src.token = vaultToken{
+			value: token,
+		}
+
+	case len(auth.AuthMethod) == 0:
+		src.token = vaultToken{
+			value: os.Getenv(api.EnvVaultToken),
+		}
+		if len(src.token.value) == 0 {
+			return errors.New("missing access token")
+		}
+	}
+	if len(src.token.value) > 0 {
+		client.SetToken(src.token.value)
+		return nil
+	}
+
+	// Check if we already logged in
+	cacheKey := vaultAuthCacheKey(src)
+	if s != nil {
+		if token, ok := s.vaultTokens[cacheKey]; ok && !token.mustRefresh() {
+			src.token = token
+			client.SetToken(token.value)
+			return nil
+		}
+	}
+
+	// Login
+	err := vaultLogin(ctx, client, src)
+	if err != nil {
+		return err
+	}
+
+	if s != nil {
+		s.vaultTokens[cacheKey] = src.token
+	}
+
+	// Done
+	return nil
+}
+
+// refreshVaultToken logins again if the token obtained through an authentication method is about to expire.
+func refreshVaultToken(ctx context.Context, client *api.Client, src *vaultSource) error {
+	if !src.token.mustRefresh() {
+		return nil
+	}
+	return vaultLogin(ctx, client, src)
+}
+
+// vaultLogin obtains a new token using the configured authentication method.
+func vaultLogin(ctx context.Context, client *api.Client, src *vaultSource) error {
+	var loginPath string
+	var loginData map[string]interface{}
+
+	auth := src.opts
+
+	mount := vaultAuthMount(auth)
+	switch auth.AuthMethod {
+	case "approle":
+		secretID := auth.SecretID
+		if len(auth.SecretIDFile) > 0 {
+			var err error
+
+			secretID, err = readSecretFile(auth.SecretIDFile)
+			if err != nil {
+				return err
+			}
+		}
+		if len(auth.RoleID) == 0 {
+			return errors.New("missing role id")
+		}
+		loginPath = "auth/" + mount + "/login"
+		loginData = map[string]interface{}{
+			"role_id":   auth.RoleID,
+			"secret_id": secretID,
+		}
+
+	case "kubernetes":
+		jwtFile := auth.JWTFile
+		if len(jwtFile) == 0 {
+			jwtFile = defaultKubernetesJWTFile
+		}
+		jwt, err := readSecretFile(jwtFile)
+		if err != nil {
+			return err
+		}
+		if len(auth.Role) == 0 {
+			return errors.New("missing role")
+		}
+		loginPath = "auth/" + mount + "/login"
+		loginData = map[string]interface{}{
+			"role": auth.Role,
+			"jwt":  jwt,
+		}
+
+	case "userpass":
+		if len(auth.Username) == 0 {
+			return errors.New("missing username")
+		}
+		loginPath = "auth/" + mount + "/login/" + url.PathEscape(auth.Username)
+		loginData = map[string]interface{}{
+			"password": auth.Password,
+		}
+
+	default:
+		return errors.New("unsupported authentication method '" + auth.AuthMethod + "'")
+	}
+
+	client.ClearToken()
+	secret, err := client.Logical().WriteWithContext(ctx, loginPath, loginData)
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.Auth == nil || len(secret.Auth.ClientToken) == 0 {
+		return errors.New("unable to authenticate")
+	}
+	src.token = vaultToken{
+		value: secret.Auth.ClientToken,
+	}
+	if secret.Auth.LeaseDuration > 0 {
+		src.token.ttl = time.Duration(secret.Auth.LeaseDuration) * time.Second
+		src.token.expiresAt = time.Now().Add(src.token.ttl)
+	}
+	client.SetToken(src.token.value)
+
+	// Done
+	return nil
+}
+
+func vaultAuthMount(auth VaultOptions) string {
+	mount := auth.AuthMount
+	if len(mount) == 0 {
+		mount = auth.AuthMethod
+	}
+	return strings.Trim(mount, "/")
+}
+
+// vaultAuthCacheKey builds the key used to share tokens between sources. It includes everything that identifies
+// the server and the login, so sources pointing to different namespaces or using different TLS identities never
+// share a token.
+func vaultAuthCacheKey(src *vaultSource) string {
+	auth := src.opts
+
+	var identity string
+	switch auth.AuthMethod {
+	case "approle":
+		identity = auth.RoleID
+	case "kubernetes":
+		identity = auth.Role
+	case "userpass":
+		identity = auth.Username
+	}
+
+	return strings.Join([]string{
+		src.address,
+		auth.Namespace,
+		auth.CACert,
+		auth.CAPath,
+		auth.ClientCert,
+		auth.ClientKey,
+		auth.TLSServerName,
+		strconv.FormatBool(auth.TLSSkipVerify),
+		auth.AuthMethod,
+		vaultAuthMount(auth),
+		identity,
+	}, "|")
+}
+
+// mustRefresh returns true if the token was obtained by logging in and two thirds of its lifetime have elapsed.
+func (t vaultToken) mustRefresh() bool {
+	if t.expiresAt.IsZero() {
+		return false
+	}
+	return !time.Now().Before(t.expiresAt.Add(-t.ttl / 3))
+}
+
+// newVaultClient creates a client using only the source settings. The VAULT_* environment variables read by the
+// default configuration of the Vault API are ignored so they do not silently change the connection.
+func newVaultClient(src *vaultSource) (*api.Client, error) {
+	if len(src.address) == 0 {
+		return nil, errors.New("missing server address")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	config := &api.Config{
+		Address: src.address,
+		HttpClient: &http.Client{
+			Transport: transport,
+			// Redirects are handled by the client
+			CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Timeout:    60 * time.Second,
+		MaxRetries: defaultVaultMaxRetries,
+	}
+	if src.opts.MaxRetries != 0 {
+		config.MaxRetries = src.opts.MaxRetries
+		if config.MaxRetries < 0 {
+			config.MaxRetries = 0
+		}
+	}
+
+	// Setup TLS if custom certificates or settings were specified
+	if len(src.opts.CACert) > 0 || len(src.opts.CAPath) > 0 || len(src.opts.ClientCert) > 0 ||
+		len(src.opts.ClientKey) > 0 || len(src.opts.TLSServerName) > 0 || src.opts.TLSSkipVerify {
+		err := config.ConfigureTLS(&api.TLSConfig{
+			CACert:        src.opts.CACert,
+			CAPath:        src.opts.CAPath,
+			ClientCert:    src.opts.ClientCert,
+			ClientKey:     src.opts.ClientKey,
+			TLSServerName: src.opts.TLSServerName,
+			Insecure:      src.opts.TLSSkipVerify,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := api.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
-	client.SetToken(token)
+	if len(src.opts.Namespace) > 0 {
+		client.SetNamespace(src.opts.Namespace)
+	} else {
+		client.ClearNamespace()
+	}
+	if len(src.token.value) > 0 {
+		client.SetToken(src.token.value)
+	} else {
+		client.ClearToken()
+	}
 
-	// Read secret
-	secret, err = client.Logical().ReadWithContext(ctx, path)
+	// Done
+	return client, nil
+}
+
+func readVaultSecret(ctx context.Context, client *api.Client, src *vaultSource) (*api.Secret, error) {
+	var secret *api.Secret
+	var err error
+
+	// Detect the engine version once
+	if len(src.readPath) == 0 {
+		err = resolveVaultPath(ctx, client, src)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(src.version) > 0 {
+		secret, err = client.Logical().ReadWithDataWithContext(ctx, src.readPath, map[string][]string{
+			"version": {src.version},
+		})
+	} else {
+		secret, err = client.Logical().ReadWithContext(ctx, src.readPath)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -105,10 +789,87 @@ func loadFromVault(ctx context.Context, source string) ([]byte, error) {
 		return nil, errors.New("data not found")
 	}
 
-	// Extract data
-	data, ok := secret.Data["data"].(map[string]interface{})
-	if !ok || data == nil {
-		return nil, errors.New("data not found")
+	// Done
+	return secret, nil
+}
+
+// resolveVaultPath detects the KV engine version of the mount where the secret is located and adds the data/
+// segment required by KV v2 if missing.
+func resolveVaultPath(ctx context.Context, client *api.Client, src *vaultSource) error {
+	var mount *vaultMount
+
+	// Mounts can be nested, so pick the longest known mount containing the path
+	cacheKey := src.address + "|" + src.opts.Namespace + "|"
+	s := getSession(ctx)
+	if s != nil {
+		matchLen := 0
+		for prefix, m := range s.vaultMounts {
+			if len(prefix) > matchLen && strings.HasPrefix(cacheKey+src.path, prefix) {
+				mount = m
+				matchLen = len(prefix)
+			}
+		}
+	}
+
+	if mount == nil {
+		mount = &vaultMount{}
+
+		// Tokens without access to the mounts endpoint or old servers will use the path as is
+		secret, err := client.Logical().ReadWithContext(ctx, "sys/internal/ui/mounts/"+src.path)
+		if err == nil && secret != nil {
+			mount.path, _ = secret.Data["path"].(string)
+			mountType, _ := secret.Data["type"].(string)
+			if mountOptions, ok := secret.Data["options"].(map[string]interface{}); ok && mountType == "kv" {
+				if version, ok2 := mountOptions["version"].(string); ok2 && version == "2" {
+					mount.kvVersion = 2
+				} else {
+					mount.kvVersion = 1
+				}
+			} else if mountType == "kv" || mountType == "generic" {
+				mount.kvVersion = 1
+			}
+
+			if s != nil && len(mount.path) > 0 {
+				s.vaultMounts[cacheKey+mount.path] = mount
+			}
+		}
+	}
+
+	src.kvVersion = mount.kvVersion
+	src.readPath = src.path
+	if mount.kvVersion == 2 && strings.HasPrefix(src.path, mount.path) {
+		subPath := src.path[len(mount.path):]
+		if !strings.HasPrefix(subPath, "data/") {
+			src.readPath = mount.path + "data/" + subPath
+		}
+	}
+
+	if len(src.version) > 0 && mount.kvVersion == 1 {
+		return errors.New("versions are only supported by KV v2 engines")
+	}
+
+	// Done
+	return nil
+}
+
+// extractVaultData gets the requested data from a secret. If a key was specified and its value is a string, it is
+// returned as is, else the value is encoded as json.
+func extractVaultData(secret *api.Secret, src *vaultSource) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	var err error
+
+	// Extract data. KV v2 engines wrap the data along with the metadata.
+	data := secret.Data
+	if src.kvVersion == 2 || (src.kvVersion == 0 && isVaultKV2Secret(secret)) {
+		var ok bool
+
+		data, ok = secret.Data["data"].(map[string]interface{})
+		if !ok {
+			data = nil
+		}
+	}
+	if data == nil {
+		return nil, false, errors.New("data not found")
 	}
 
 	// Prepare re-encoded for further processing
@@ -116,33 +877,23 @@ func loadFromVault(ctx context.Context, source string) ([]byte, error) {
 	enc.SetEscapeHTML(false)
 
 	// Was a key specified?
-	if len(keys) == 0 {
+	if len(src.keys) == 0 {
 		// No, encode the whole data
 		err = enc.Encode(data)
 	} else {
 		var value interface{}
 
-		// Yes, transverse it
-		keysLength := len(keys)
-		for keyIdx := 0; keyIdx < keysLength-1; keyIdx++ {
-			data, ok = data[keys[keyIdx]].(map[string]interface{})
-			if !ok || data == nil {
-				return nil, errors.New("key not found")
-			}
-		}
-
-		// Get the final value
-		value, ok = data[keys[keysLength-1]]
-		if !ok || value == nil {
-			return nil, errors.New("key not found")
+		value, err = getVaultValue(data, src.keys)
+		if err != nil {
+			return nil, false, err
 		}
 
 		// Check special cases
 		switch v := value.(type) {
 		case string:
-			return []byte(v), nil
+			return []byte(v), true, nil
 		case *string:
-			return []byte(*v), nil
+			return []byte(*v), true, nil
 		}
 
 		// Encode the rest
@@ -151,9 +902,55 @@ func loadFromVault(ctx context.Context, source string) ([]byte, error) {
 
 	// Check for encoding errors
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// Done
-	return buf.Bytes(), nil
+	return buf.Bytes(), false, nil
+}
+
+func getVaultValue(data map[string]interface{}, keys []string) (interface{}, error) {
+	var ok bool
+
+	// Transverse the data
+	keysLength := len(keys)
+	for keyIdx := 0; keyIdx < keysLength-1; keyIdx++ {
+		data, ok = data[keys[keyIdx]].(map[string]interface{})
+		if !ok || data == nil {
+			return nil, errors.New("key not found")
+		}
+	}
+
+	// Get the final value
+	value, ok := data[keys[keysLength-1]]
+	if !ok || value == nil {
+		return nil, errors.New("key not found")
+	}
+
+	// Done
+	return value, nil
+}
+
+// isVaultKV2Secret checks if a secret read from an unknown engine looks like a KV v2 one
+func isVaultKV2Secret(secret *api.Secret) bool {
+	_, hasData := secret.Data["data"].(map[string]interface{})
+	_, hasMetadata := secret.Data["metadata"]
+	return hasData && hasMetadata
+}
+
+// getVaultSecretVersion returns the version of a KV v2 secret or zero if not available
+func getVaultSecretVersion(secret *api.Secret, src *vaultSource) int64 {
+	if src.kvVersion == 1 {
+		return 0
+	}
+	metadata, ok := secret.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	if version, ok2 := metadata["version"].(json.Number); ok2 {
+		if v, err := version.Int64(); err == nil {
+			return v
+		}
+	}
+	return 0
 }
@@ -0,0 +1,79 @@
+package go_config_reader
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/randlabs/go-config-reader/internal/preprocessor"
+)
+
+// -----------------------------------------------------------------------------
+
+// MacroHandler is a function that returns the replacement of a custom macro like ${NAME:content}. The content is
+// passed with the macros embedded in it already expanded.
+type MacroHandler func(ctx context.Context, content string) ([]byte, error)
+
+// -----------------------------------------------------------------------------
+
+var macrosMtx = sync.RWMutex{}
+var macros = make(map[string]MacroHandler)
+
+// -----------------------------------------------------------------------------
+
+// RegisterMacro registers a handler for the macros with the given name, like ${SSM:parameter}. Names must start
+// with an uppercase letter followed by uppercase letters, digits or underscores and cannot be the one of a built-in
+// macro. RegisterMacro panics if the name is not valid. Registering a handler for an existing name replaces the
+// previous one and passing a nil handler removes it.
+func RegisterMacro(name string, handler MacroHandler) {
+	if !isValidMacroName(name) {
+		panic("invalid macro name '" + name + "'")
+	}
+
+	macrosMtx.Lock()
+	defer macrosMtx.Unlock()
+
+	if handler != nil {
+		macros[name] = handler
+	} else {
+		delete(macros, name)
+	}
+}
+
+// lookupMacro returns the handler of a custom macro giving preference to the ones specified in the options
+func lookupMacro(ctx context.Context, name string) MacroHandler {
+	if s := getSession(ctx); s != nil && s.options.Macros != nil {
+		if handler, ok := s.options.Macros[name]; ok && handler != nil {
+			return handler
+		}
+	}
+
+	macrosMtx.RLock()
+	handler := macros[name]
+	macrosMtx.RUnlock()
+
+	// Done
+	return handler
+}
+
+// validateMacros checks the names of the macros specified in the options
+func validateMacros(options *Options) error {
+	for name := range options.Macros {
+		if !isValidMacroName(name) {
+			return errors.New("invalid macro name '" + name + "'")
+		}
+	}
+	return nil
+}
+
+func isValidMacroName(name string) bool {
+	if len(name) == 0 || preprocessor.IsBuiltInTag(name) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !preprocessor.IsTagNameChar(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// loadFileMacro reads the file referenced by a ${FILE:path} macro. The optional trim=true and encoding=base64 query
+// parameters remove the trailing newline and encode the content respectively.
+func loadFileMacro(ctx context.Context, content string) ([]byte, error) {
+	var trim bool
+	var encodeBase64 bool
+
+	// Parse options
+	i := strings.LastIndex(content, "?")
+	if i >= 0 {
+		queryMap, err := url.ParseQuery(content[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		content = content[:i]
+
+		for k, v := range queryMap {
+			if len(v) > 0 {
+				switch k {
+				case "trim":
+					trim, err = strconv.ParseBool(v[0])
+					if err != nil {
+						return nil, errors.New("invalid trim value")
+					}
+
+				case "encoding":
+					switch v[0] {
+					case "base64":
+						encodeBase64 = true
+					case "", "none":
+						encodeBase64 = false
+					default:
+						return nil, errors.New("invalid file encoding")
+					}
+				}
+			}
+		}
+	}
+	if len(content) == 0 {
+		return nil, errors.New("invalid path")
+	}
+
+	// Read the file like a ${SRC:...} one, so it is also watched
+	ls := &loadedSource{
+		loader: fileLoader{},
+		source: content,
+	}
+	data, err := loadFromFile(withLoadedSource(ctx, ls), content)
+	if err != nil {
+		return nil, err
+	}
+	if s := getSession(ctx); s != nil {
+		s.sources = append(s.sources, ls)
+	}
+
+	if trim {
+		data = bytes.TrimSuffix(data, []byte("\n"))
+		data = bytes.TrimSuffix(data, []byte("\r"))
+	}
+	if encodeBase64 {
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+		base64.StdEncoding.Encode(encoded, data)
+		data = encoded
+	}
+
+	// Done
+	return data, nil
+}
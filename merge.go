@@ -0,0 +1,142 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// -----------------------------------------------------------------------------
+
+// ArrayMergeStrategy specifies how arrays are merged when more than one source is loaded.
+type ArrayMergeStrategy int
+
+const (
+	// ArrayMergeReplace replaces the previous array with the new one.
+	ArrayMergeReplace ArrayMergeStrategy = iota
+
+	// ArrayMergeAppend appends the items of the new array to the previous one.
+	ArrayMergeAppend
+
+	// ArrayMergeByKey deep merges objects having the same value in the key field and appends the rest.
+	ArrayMergeByKey
+)
+
+// fragment is a configuration document that is merged with others.
+type fragment struct {
+	name   string
+	data   []byte
+	format Format
+}
+
+// -----------------------------------------------------------------------------
+
+// mergeFragments converts the fragments to json and deep merges them in order using the array merge strategy set in
+// the options of the session
+func mergeFragments(ctx context.Context, fragments []fragment) ([]byte, error) {
+	var merged interface{}
+
+	strategy, mergeKey := ArrayMergeReplace, ""
+	if s := getSession(ctx); s != nil {
+		strategy, mergeKey = s.options.ArrayMergeStrategy, s.options.ArrayMergeKey
+	}
+
+	for idx, frag := range fragments {
+		var doc interface{}
+
+		// The conversion may modify the data
+		encodedJSON, err := convertToJSON(append([]byte{}, frag.data...), frag.format)
+		if err == nil {
+			doc, err = decodeJSON(encodedJSON)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid document '%s': %w", frag.name, err)
+		}
+
+		if idx == 0 {
+			merged = doc
+		} else {
+			merged = mergeValues(merged, doc, strategy, mergeKey)
+		}
+	}
+
+	// Done
+	return encodeJSON(merged)
+}
+
+// mergeValues deep merges src into dst and returns the result
+func mergeValues(dst interface{}, src interface{}, strategy ArrayMergeStrategy, key string) interface{} {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			return src
+		}
+		for k, v := range s {
+			if dv, found := d[k]; found {
+				d[k] = mergeValues(dv, v, strategy, key)
+			} else {
+				d[k] = v
+			}
+		}
+		return d
+
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok {
+			return src
+		}
+		switch strategy {
+		case ArrayMergeAppend:
+			return append(d, s...)
+
+		case ArrayMergeByKey:
+			return mergeArraysByKey(d, s, strategy, key)
+		}
+	}
+
+	return src
+}
+
+func mergeArraysByKey(dst []interface{}, src []interface{}, strategy ArrayMergeStrategy, key string) []interface{} {
+	for _, item := range src {
+		merged := false
+
+		if obj, ok := item.(map[string]interface{}); ok {
+			if id, found := obj[key]; found {
+				for idx, dstItem := range dst {
+					if dstObj, ok2 := dstItem.(map[string]interface{}); ok2 && reflect.DeepEqual(dstObj[key], id) {
+						dst[idx] = mergeValues(dstObj, obj, strategy, key)
+						merged = true
+						break
+					}
+				}
+			}
+		}
+
+		if !merged {
+			dst = append(dst, item)
+		}
+	}
+	return dst
+}
+
+// decodeJSON parses a json document keeping numbers as is
+func decodeJSON(data []byte) (interface{}, error) {
+	var value interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after top-level value")
+	}
+	return value, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io/fs"
 	"os"
 
 	"github.com/qri-io/jsonschema"
@@ -16,6 +17,25 @@ type Options struct {
 	// Optional embedded source.
 	Source string
 
+	// Optional list of sources to load in order and deep merge into a single document. The main source,
+	// if any, is applied on top of them.
+	Sources []string
+
+	// Specifies how arrays are merged when more than one source is loaded. Arrays are replaced by default.
+	ArrayMergeStrategy ArrayMergeStrategy
+
+	// Specifies the name of the field that identifies array items when the ArrayMergeByKey strategy is used. It is
+	// required by that strategy.
+	ArrayMergeKey string
+
+	// Optional filesystem, like an embed.FS, used to read embed:// sources and file sources with relative paths,
+	// including directories, patterns and the ones referenced by ${SRC:...} and ${FILE:...} macros. Absolute paths
+	// are still read from the operating system's filesystem.
+	FS fs.FS
+
+	// If set, file patterns like conf.d/*.json that match no files load an empty object instead of failing.
+	AllowEmptyGlob bool
+
 	// Environment variable that contains source.
 	EnvironmentVariable string
 
@@ -27,6 +47,21 @@ type Options struct {
 	// Use a custom loader for the configuration settings.
 	Callback LoaderCallback
 
+	// Optional source loaders to use for this load operation. Keys are lowercase scheme names and they
+	// have preference over the ones registered with RegisterLoader.
+	Loaders map[string]SourceLoader
+
+	// Optional macro handlers to use for this load operation. Keys are macro names, like SSM for ${SSM:...}, and
+	// they have preference over the ones registered with RegisterMacro.
+	Macros map[string]MacroHandler
+
+	// Specifies the settings used to access Hashicorp Vault servers.
+	Vault VaultOptions
+
+	// Forces the format of the main source. If not set, it is detected from the source. Layered sources and the
+	// ones referenced by ${SRC:...} macros are not affected.
+	Format Format
+
 	// Specifies an optional json schema validator.
 	Schema string
 
@@ -35,6 +70,9 @@ type Options struct {
 
 	// Optional context to use while loading the configuration.
 	Context context.Context
+
+	// Specifies the options used by Watch.
+	Reload ReloadOptions
 }
 
 // LoaderCallback is a function to call that must return a valid configuration json when possible.
@@ -47,14 +85,32 @@ type ExtendedValidator func(settings interface{}) error
 
 // Load settings from the specified source.
 func Load(options Options, settings interface{}) error {
-	var encodedJSON []byte
-	var err error
-
 	ctx := options.Context
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	// Load, merge and convert all the sources
+	s, encodedJSON, err := loadSession(ctx, &options)
+	if err != nil {
+		return err
+	}
+
+	// Validate and parse the configuration settings
+	err = decodeSettings(&options, encodedJSON, settings)
+	if err != nil {
+		s.release()
+		return err
+	}
+
+	// Done
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// resolveSource gets the main source from the options, the environment variable or the command-line
+func resolveSource(options *Options) (string, error) {
 	// If a source was passed, use it
 	source := options.Source
 
@@ -91,7 +147,7 @@ func Load(options Options, settings interface{}) error {
 			for idx, value := range os.Args[1:] {
 				if (hasCmdLineOption && value == cmdLineOption) || (hasCmdLineOptionShort && value == cmdLineOptionShort) {
 					if idx+2 >= len(os.Args) {
-						return errors.New("missing source in '" + value + "' parameter.")
+						return "", errors.New("missing source in '" + value + "' parameter.")
 					}
 					source = os.Args[idx+2]
 					break
@@ -100,34 +156,139 @@ func Load(options Options, settings interface{}) error {
 		}
 	}
 
+	// Done
+	return source, nil
+}
+
+// loadSession runs a new load operation and returns the resulting json document along with the session
+// that keeps track of the loaded sources
+func loadSession(ctx context.Context, options *Options) (*session, []byte, error) {
+	s := newSession(options)
+	encodedJSON, err := loadSources(withSession(ctx, s), options)
+	if err != nil {
+		// Release the resources held by the sources loaded before the failure
+		s.release()
+		return nil, nil, err
+	}
+	return s, encodedJSON, nil
+}
+
+// loadSources loads all the configured sources and merges them into a single json document
+func loadSources(ctx context.Context, options *Options) ([]byte, error) {
+	if options.ArrayMergeStrategy == ArrayMergeByKey && len(options.ArrayMergeKey) == 0 {
+		return nil, errors.New("array merge key not set")
+	}
+	err := validateMacros(options)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := resolveSource(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The main source is applied on top of the layered ones
+	sources := options.Sources
+	if len(source) > 0 {
+		sources = append(sources[:len(sources):len(sources)], source)
+	}
+
 	// If we reach here and no source, throw error
-	if len(source) == 0 {
-		return errors.New("source not defined")
+	if len(sources) == 0 {
+		return nil, errors.New("source not defined")
+	}
+
+	// The forced format only applies to the main source
+	formatOf := func(idx int) Format {
+		if len(source) > 0 && idx == len(sources)-1 {
+			return options.Format
+		}
+		return FormatAuto
+	}
+
+	// Single sources do not need to be merged
+	if len(sources) == 1 {
+		return loadDocument(ctx, options, sources[0], formatOf(0))
+	}
+
+	var merged interface{}
+	for idx, src := range sources {
+		var encodedJSON []byte
+		var doc interface{}
+
+		encodedJSON, err = loadDocument(ctx, options, src, formatOf(idx))
+		if err != nil {
+			return nil, err
+		}
+		doc, err = decodeJSON(encodedJSON)
+		if err != nil {
+			return nil, newLoadError(err)
+		}
+
+		if idx == 0 {
+			merged = doc
+		} else {
+			merged = mergeValues(merged, doc, options.ArrayMergeStrategy, options.ArrayMergeKey)
+		}
+	}
+
+	encodedJSON, err := encodeJSON(merged)
+	if err != nil {
+		return nil, newLoadError(err)
 	}
 
+	// Done
+	return encodedJSON, nil
+}
+
+// loadDocument loads a single source, expands its variables and converts it to json. If forcedFormat is set, it
+// has preference over the format reported by the loader.
+func loadDocument(ctx context.Context, options *Options, source string, forcedFormat Format) ([]byte, error) {
+	var encodedJSON []byte
+	var err error
+
 	// Load content from callback if one was provided
+	format := FormatAuto
 	if options.Callback != nil {
 		encodedJSON, err = loadFromCallback(ctx, options.Callback, source)
+		format = formatFromSource(source)
 	} else {
-		encodedJSON, err = internalLoad(ctx, source)
+		encodedJSON, format, err = internalLoad(ctx, source)
 	}
 	if err != nil {
-		return newLoadError(err)
+		return nil, newLoadError(err)
+	}
+
+	// A forced format has preference
+	if forcedFormat != FormatAuto {
+		format = forcedFormat
 	}
 
 	// Expand variables embedded inside loaded json
-	encodedJSON, err = expandVars(ctx, encodedJSON, 1)
+	encodedJSON, err = expandDocument(ctx, encodedJSON, format)
 	if err != nil {
-		return newLoadError(err)
+		return nil, newLoadError(err)
 	}
 
 	// If resulting configuration is empty, throw error
 	if len(encodedJSON) == 0 {
-		return errors.New("empty data")
+		return nil, errors.New("empty data")
+	}
+
+	// Convert the loaded document to json if it is in another format
+	encodedJSON, err = convertToJSON(encodedJSON, format)
+	if err != nil {
+		return nil, newLoadError(err)
 	}
 
-	// Remove comments from json
-	removeComments(encodedJSON)
+	// Done
+	return encodedJSON, nil
+}
+
+// decodeSettings validates the json document and parses it into the settings object
+func decodeSettings(options *Options, encodedJSON []byte, settings interface{}) error {
+	var err error
 
 	// Validate against a schema if one is provided
 	if len(options.Schema) > 0 {
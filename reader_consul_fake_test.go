@@ -0,0 +1,122 @@
+package go_config_reader_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// fakeConsul mimics the subset of the Consul KV API used by the loader, including blocking queries.
+type fakeConsul struct {
+	t       *testing.T
+	mtx     sync.Mutex
+	server  *httptest.Server
+	token   string
+	keys    map[string]string
+	index   uint64
+	changed chan struct{} // Closed and replaced on each write
+}
+
+//------------------------------------------------------------------------------
+
+func newFakeConsul(t *testing.T) *fakeConsul {
+	fc := &fakeConsul{
+		t:       t,
+		token:   "consul-token",
+		keys:    make(map[string]string),
+		index:   1,
+		changed: make(chan struct{}),
+	}
+	fc.server = httptest.NewServer(http.HandlerFunc(fc.handle))
+	return fc
+}
+
+func (fc *fakeConsul) Close() {
+	fc.server.Close()
+}
+
+// url returns a consul source url for the given key
+func (fc *fakeConsul) url(key string) string {
+	return "consul://" + strings.TrimPrefix(fc.server.URL, "http://") + "/" + key + "?token=" + fc.token
+}
+
+// put sets the value of a key
+func (fc *fakeConsul) put(key string, value string) {
+	fc.mtx.Lock()
+	defer fc.mtx.Unlock()
+
+	fc.keys[key] = value
+	fc.index += 1
+	close(fc.changed)
+	fc.changed = make(chan struct{})
+}
+
+func (fc *fakeConsul) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" || !strings.HasPrefix(r.URL.Path, "/v1/kv/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("X-Consul-Token") != fc.token {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	query := r.URL.Query()
+
+	// Blocking queries wait until the index changes
+	fc.mtx.Lock()
+	if index, _ := strconv.ParseUint(query.Get("index"), 10, 64); index > 0 && index >= fc.index {
+		wait, _ := time.ParseDuration(query.Get("wait"))
+		changed := fc.changed
+		fc.mtx.Unlock()
+
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+
+		fc.mtx.Lock()
+	}
+	defer fc.mtx.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(fc.index, 10))
+
+	if _, ok := query["recurse"]; ok {
+		pairs := make([]map[string]interface{}, 0)
+		for k, v := range fc.keys {
+			if strings.HasPrefix(k, key) {
+				pairs = append(pairs, map[string]interface{}{
+					"Key":   k,
+					"Value": []byte(v),
+				})
+			}
+		}
+		if len(pairs) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairs[i]["Key"].(string) < pairs[j]["Key"].(string)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pairs)
+		return
+	}
+
+	value, ok := fc.keys[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_, _ = w.Write([]byte(value))
+}
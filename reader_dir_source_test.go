@@ -0,0 +1,147 @@
+package go_config_reader_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+func TestDirSource(t *testing.T) {
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// Create a conf.d style directory. Files without a known extension, hidden files and subdirectories are ignored.
+	writeFileAtomic(t, filepath.Join(dir, "10-base.json"), goodSettingsJSON)
+	writeFileAtomic(t, filepath.Join(dir, "20-override.yaml"), "name: overridden\nserver:\n  port: 9000\n")
+	writeFileAtomic(t, filepath.Join(dir, "README.txt"), "not a configuration file")
+	writeFileAtomic(t, filepath.Join(dir, ".30-hidden.json"), `{ "name": "hidden" }`)
+	err := os.Mkdir(filepath.Join(dir, "40-subdir.json"), 0700)
+	if err != nil {
+		t.Fatalf("unable to create directory [err=%v]", err)
+	}
+
+	expectedSettings := goodSettings
+	expectedSettings.Name = "overridden"
+	expectedSettings.Server.Port = 9000
+
+	// Fragments are merged in lexical order using the dir scheme or a trailing slash
+	for _, source := range []string{"dir://" + dir, dir + string(filepath.Separator)} {
+		settings := TestSettings{}
+		err = cf.Load(cf.Options{
+			Source: source,
+			Schema: schemaJSON,
+		}, &settings)
+		if err != nil {
+			t.Fatalf("unable to load settings [source=%v] [err=%v]", source, err)
+		}
+		if !reflect.DeepEqual(settings, expectedSettings) {
+			t.Fatalf("settings mismatch [source=%v]", source)
+		}
+	}
+
+	// A malformed fragment must report its name along with the parse error
+	writeFileAtomic(t, filepath.Join(dir, "30-broken.json"), `{ "name": ] }`)
+	err = cf.Load(cf.Options{
+		Source: "dir://" + dir,
+	}, &TestSettings{})
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+	if !strings.Contains(err.Error(), "invalid document '30-broken.json': invalid character ']'") {
+		t.Fatalf("unexpected error [err=%v]", err)
+	}
+}
+
+func TestDirSourceFilesMode(t *testing.T) {
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// Mimic a Kubernetes secret mount where each key is a symbolic link to a file inside a hidden directory
+	dataDir := filepath.Join(dir, "..2024_01_01_00_00_00.000000000")
+	err := os.Mkdir(dataDir, 0700)
+	if err == nil {
+		err = os.Symlink(filepath.Base(dataDir), filepath.Join(dir, "..data"))
+	}
+	if err != nil {
+		t.Fatalf("unable to create directory [err=%v]", err)
+	}
+	writeFileAtomic(t, filepath.Join(dataDir, "url"), "http://127.0.0.1:8003\n")
+	writeFileAtomic(t, filepath.Join(dataDir, "apiToken"), "some-api-access-token\n")
+	for _, name := range []string{"url", "apiToken"} {
+		err = os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("unable to create symbolic link [err=%v]", err)
+		}
+	}
+
+	// Load the node settings from the directory
+	settings := TestSettings{}
+	err = cf.Load(cf.Options{
+		Sources: []string{
+			"data://" + goodSettingsJSON,
+		},
+		Source: `data://{ "node": ${SRC:dir://` + dir + `?mode=files&trim=true} }`,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Without trimming, the trailing newline is kept
+	err = cf.Load(cf.Options{
+		Sources: []string{
+			"data://" + goodSettingsJSON,
+		},
+		Source: `data://{ "node": ${SRC:dir://` + dir + `?mode=files} }`,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Node.ApiToken != "some-api-access-token\n" {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+func TestWatchDirSource(t *testing.T) {
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	writeFileAtomic(t, filepath.Join(dir, "10-base.json"), goodSettingsJSON)
+
+	// Start watching
+	settings := TestSettings{}
+	resultCh := startTestWatch(t, cf.Options{
+		Source: "dir://" + dir,
+		Schema: schemaJSON,
+		Reload: cf.ReloadOptions{
+			Debounce: 50 * time.Millisecond,
+		},
+	}, &settings)
+
+	// Add a new fragment
+	writeFileAtomic(t, filepath.Join(dir, "20-override.json"), strings.Replace(goodSettingsJSON, "string test", "new string test", 1))
+
+	result := waitWatchResult(t, resultCh)
+	if result.err != nil {
+		t.Fatalf("unexpected reload error [err=%v]", result.err)
+	}
+	if result.settings.Name != "new string test" {
+		t.Fatalf("settings mismatch")
+	}
+}
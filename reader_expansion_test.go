@@ -60,3 +60,252 @@ func TestComplexVariableExpansion(t *testing.T) {
 		t.Fatalf("settings mismatch")
 	}
 }
+
+func TestEscapedVariableExpansion(t *testing.T) {
+	// Save test environment variables and restore on exit
+	defer scopedEnvVar("GO_READER_NAME")()
+	defer scopedEnvVar("GO_READER_API_TOKEN")()
+
+	// Values with quotes, backslashes and newlines must not break the document nor inject keys
+	name := "string \"test\"\\\n"
+	_ = os.Setenv("GO_READER_NAME", name)
+	_ = os.Setenv("GO_READER_API_TOKEN", `", "integerValue": 200, "x": "`)
+
+	modifiedSettingsJSON := strings.Replace(goodSettingsJSON, `"string test"`, `"${ENV:GO_READER_NAME}"`, 1)
+	modifiedSettingsJSON = strings.Replace(modifiedSettingsJSON, "some-api-access-token", "${ENV:GO_READER_API_TOKEN}", 1)
+
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: "data://" + modifiedSettingsJSON,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Name != name || settings.IntegerValue != 100 || settings.Node.ApiToken != os.Getenv("GO_READER_API_TOKEN") {
+		t.Fatalf("settings mismatch")
+	}
+
+	// The same applies to YAML double-quoted strings
+	modifiedSettingsYAML := strings.Replace(goodSettingsYAML, "name: string test", `name: "${ENV:GO_READER_NAME}"`, 1)
+
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: "data://" + modifiedSettingsYAML,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Name != name {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Explicit modes insert the value as a string literal or as is
+	_ = os.Setenv("GO_READER_NAME", "string test")
+	_ = os.Setenv("GO_READER_API_TOKEN", `some-api-access-token`)
+
+	modifiedSettingsJSON = strings.Replace(goodSettingsJSON, `"string test"`, `${ENV|json:GO_READER_NAME}`, 1)
+	modifiedSettingsJSON = strings.Replace(modifiedSettingsJSON, "some-api-access-token", "${ENV|raw:GO_READER_API_TOKEN}", 1)
+
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: "data://" + modifiedSettingsJSON,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Unknown modes are rejected
+	err = cf.Load(cf.Options{
+		Source: `data://{ "name": "${ENV|bad:GO_READER_NAME}" }`,
+	}, &settings)
+	if err == nil {
+		t.Fatalf("load succeeded with an unknown tag mode")
+	}
+}
+
+func TestEscapedVariableExpansionInYAML(t *testing.T) {
+	// Save test environment variables and restore on exit
+	defer scopedEnvVar("GO_READER_NAME")()
+	defer scopedEnvVar("GO_READER_API_TOKEN")()
+
+	_ = os.Setenv("GO_READER_NAME", "string \"test\"")
+	_ = os.Setenv("GO_READER_API_TOKEN", "token\"\ninjected: true\nother: \"")
+
+	// Quotes inside plain scalars, single-quoted strings and block scalars must not hide the strings that follow
+	settingsYAML := `screen: 5" wide
+token: "${ENV:GO_READER_API_TOKEN}"
+quote: 'it''s "here"'
+notes: |
+  say "hi
+  it's
+list:
+  - it's
+  - "${ENV:GO_READER_NAME}" # it's
+`
+
+	settings := make(map[string]interface{})
+	err := cf.Load(cf.Options{
+		Source: "data://" + settingsYAML,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	expectedSettings := map[string]interface{}{
+		"screen": `5" wide`,
+		"quote":  `it's "here"`,
+		"notes":  "say \"hi\nit's\n",
+		"list":   []interface{}{"it's", `string "test"`},
+		"token":  os.Getenv("GO_READER_API_TOKEN"),
+	}
+	if !reflect.DeepEqual(settings, expectedSettings) {
+		t.Fatalf("settings mismatch [settings=%v]", settings)
+	}
+}
+
+func TestEscapedVariableExpansionInTOML(t *testing.T) {
+	// Save test environment variables and restore on exit
+	defer scopedEnvVar("GO_READER_NAME")()
+	defer scopedEnvVar("GO_READER_API_TOKEN")()
+
+	_ = os.Setenv("GO_READER_NAME", "string \"test\"")
+	_ = os.Setenv("GO_READER_API_TOKEN", "token\"\ninjected = true\nother = \"")
+
+	// Quotes inside multi-line strings must not hide the strings that follow
+	settingsTOML := `screen = '5" wide'
+notes = '''
+say "hi
+it's
+'''
+message = """
+say "${ENV:GO_READER_NAME}""""
+token = "${ENV:GO_READER_API_TOKEN}" # it's
+`
+
+	settings := make(map[string]interface{})
+	err := cf.Load(cf.Options{
+		Source: "data://" + settingsTOML,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	expectedSettings := map[string]interface{}{
+		"screen":  `5" wide`,
+		"notes":   "say \"hi\nit's\n",
+		"message": `say "string "test""`,
+		"token":   os.Getenv("GO_READER_API_TOKEN"),
+	}
+	if !reflect.DeepEqual(settings, expectedSettings) {
+		t.Fatalf("settings mismatch [settings=%v]", settings)
+	}
+}
+
+func TestDefaultVariableExpansion(t *testing.T) {
+	// Save test environment variables and restore on exit
+	defer scopedEnvVar("GO_READER_NAME")()
+	defer scopedEnvVar("GO_READER_DEFAULT_NAME")()
+	defer scopedEnvVar("GO_READER_UNSET")()
+	defer scopedEnvVar("GO_READER_EMPTY")()
+
+	_ = os.Setenv("GO_READER_NAME", "string test")
+	_ = os.Setenv("GO_READER_DEFAULT_NAME", "string test")
+	_ = os.Unsetenv("GO_READER_UNSET")
+	_ = os.Setenv("GO_READER_EMPTY", "")
+
+	checkName := func(macro string, expected string) {
+		settings := TestSettings{}
+		err := cf.Load(cf.Options{
+			Source: "data://" + strings.Replace(goodSettingsJSON, `"string test"`, `"`+macro+`"`, 1),
+			Schema: schemaJSON,
+		}, &settings)
+		if err != nil {
+			t.Fatalf("unable to load settings [macro=%v] [err=%v]", macro, err)
+		}
+		if settings.Name != expected {
+			t.Fatalf("settings mismatch [macro=%v] [name=%v]", macro, settings.Name)
+		}
+	}
+
+	// Defaults, which may contain other macros, are used if the variable is not set or it is empty
+	checkName("${ENV:GO_READER_UNSET:-string test}", "string test")
+	checkName("${ENV:GO_READER_EMPTY:-string test}", "string test")
+	checkName("${ENV:GO_READER_UNSET:-${ENV:GO_READER_DEFAULT_NAME}}", "string test")
+	checkName("${ENV:GO_READER_UNSET:-a:b}", "a:b")
+
+	// And they are not expanded if the variable is set
+	checkName("${ENV:GO_READER_NAME:-${ENV:GO_READER_UNSET}}", "string test")
+
+	// Alternate values are used only if the variable is set and not empty
+	checkName("${ENV:GO_READER_NAME:+alt ${ENV:GO_READER_NAME}}", "alt string test")
+	checkName("name${ENV:GO_READER_EMPTY:+alt}", "name")
+	checkName("name${ENV:GO_READER_UNSET:+${ENV:GO_READER_UNSET}}", "name")
+
+	// Required variables fail with the given message
+	checkName("${ENV:GO_READER_NAME:?name is required}", "string test")
+
+	err := cf.Load(cf.Options{
+		Source: `data://{ "name": "${ENV:GO_READER_UNSET:?name is required}" }`,
+	}, &TestSettings{})
+	if err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Fatalf("unexpected error [err=%v]", err)
+	}
+}
+
+func TestEscapedMacros(t *testing.T) {
+	// Save test environment variables and restore on exit
+	defer scopedEnvVar("GO_READER_NAME")()
+
+	_ = os.Setenv("GO_READER_NAME", "string test")
+
+	// Escaped macros are emitted as is, even inside other macros
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: `data://{
+			"name": "${ENV:GO_READER_NAME}",
+			"node": {
+				"url": "$${ENV:GO_READER_NAME}",
+				"apiToken": "${SRC:data://$${ENV:GO_READER_NAME}} $$${X}"
+			}
+		}`,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Name != "string test" ||
+		settings.Node.Url != "${ENV:GO_READER_NAME}" ||
+		settings.Node.ApiToken != "${ENV:GO_READER_NAME} $${X}" {
+		t.Fatalf("settings mismatch [settings=%+v]", settings)
+	}
+}
+
+func TestEscapedMacrosInValues(t *testing.T) {
+	// Save test environment variables and restore on exit
+	defer scopedEnvVar("GO_READER_NAME")()
+	defer scopedEnvVar("GO_READER_UNSET")()
+
+	_ = os.Setenv("GO_READER_NAME", "p$${x}")
+	_ = os.Unsetenv("GO_READER_UNSET")
+
+	// Values are inserted as they are, only the escaped macros written in the document are unescaped
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: `data://{
+			"name": "${ENV:GO_READER_NAME}",
+			"node": {
+				"url": "${ENV:GO_READER_UNSET:-$${X}}",
+				"apiToken": ${ENV|json:GO_READER_NAME}
+			}
+		}`,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Name != "p$${x}" || settings.Node.Url != "${X}" || settings.Node.ApiToken != "p$${x}" {
+		t.Fatalf("settings mismatch [settings=%+v]", settings)
+	}
+}
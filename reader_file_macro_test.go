@@ -0,0 +1,79 @@
+package go_config_reader_test
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+func TestFileMacro(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cr")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory [err=%v]", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// Save a secret having characters that must be escaped
+	secret := "-----BEGIN \"KEY\"-----\n\\ $${ENV:X} ${ENV:X}\n-----END \"KEY\"-----\n"
+	secretFile := filepath.Join(dir, "secret.pem")
+	err = ioutil.WriteFile(secretFile, []byte(secret), 0600)
+	if err != nil {
+		t.Fatalf("unable to save secret file [err=%v]", err)
+	}
+
+	// Load the file as is, trimmed and base64 encoded, both inside and outside strings
+	settings := TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: `data://{
+			"name": ${FILE:` + secretFile + `},
+			"node": {
+				"url": "${FILE:` + secretFile + `?trim=true}",
+				"apiToken": ${FILE:file://` + secretFile + `?encoding=base64}
+			}
+		}`,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Name != secret ||
+		settings.Node.Url != secret[:len(secret)-1] ||
+		settings.Node.ApiToken != base64.StdEncoding.EncodeToString([]byte(secret)) {
+		t.Fatalf("settings mismatch [settings=%+v]", settings)
+	}
+
+	// Embedded files and relative paths are read from the filesystem set in the options
+	for _, filename := range []string{"embed://secrets/password", "secrets/password"} {
+		settings = TestSettings{}
+		err = cf.Load(cf.Options{
+			Source: `data://{ "name": ${FILE:` + filename + `?trim=true} }`,
+			FS: fstest.MapFS{
+				"secrets/password": &fstest.MapFile{
+					Data: []byte("pass\"word\n"),
+				},
+			},
+		}, &settings)
+		if err != nil {
+			t.Fatalf("unable to load settings [filename=%v] [err=%v]", filename, err)
+		}
+		if settings.Name != "pass\"word" {
+			t.Fatalf("settings mismatch [filename=%v] [settings=%+v]", filename, settings)
+		}
+	}
+
+	// Missing files must fail
+	err = cf.Load(cf.Options{
+		Source: `data://{ "name": ${FILE:` + filepath.Join(dir, "missing") + `} }`,
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+}
@@ -0,0 +1,110 @@
+package go_config_reader_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+func TestContentTypeFormat(t *testing.T) {
+	// Create a test http server that serves documents without extension
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/settings":
+			pos := strings.Index(goodSettingsJSON, `"server": {`)
+			end := strings.Index(goodSettingsJSON, `"node": {`)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(goodSettingsJSON[:pos] + `"server": ${SRC:http://` + r.Host + "/server},\n" +
+				goodSettingsJSON[end:]))
+			return
+
+		case "/toml-settings":
+			w.Header().Set("Content-Type", "application/toml")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(goodSettingsTOML))
+			return
+
+		case "/server":
+			w.Header().Set("Content-Type", "application/yaml")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ip: 127.0.0.1\nport: 8001\npoolSize: 64\nallowedAddresses: [ 127.0.0.1, \"::1\" ]\n"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer svr.Close()
+
+	// Load configuration from web
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: svr.URL + "/settings",
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		dumpValidationErrors(t, err)
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+
+	// Check if settings are the expected
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Load TOML configuration from web
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: svr.URL + "/toml-settings",
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		dumpValidationErrors(t, err)
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+func TestFormatHint(t *testing.T) {
+	// A YAML document which content sniffing would not recognize
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: "data://?format=yaml,{ name: string test, integerValue: 100, floatValue: 100.3 }",
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Name != "string test" || settings.IntegerValue != 100 || settings.FloatValue != 100.3 {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Also a forced format
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: "data://{ name: string test, integerValue: 100, floatValue: 100.3 }",
+		Format: cf.FormatYAML,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if settings.Name != "string test" || settings.IntegerValue != 100 || settings.FloatValue != 100.3 {
+		t.Fatalf("settings mismatch")
+	}
+
+	// And an unknown format must fail
+	err = cf.Load(cf.Options{
+		Source: "data://?format=xml,<settings />",
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+}
@@ -0,0 +1,146 @@
+package go_config_reader_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+func TestGlobSource(t *testing.T) {
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	writeFileAtomic(t, filepath.Join(dir, "10-base.json"), goodSettingsJSON)
+	writeFileAtomic(t, filepath.Join(dir, "20-override.yaml"), "name: overridden\nserver:\n  port: 9000\n")
+
+	expectedSettings := goodSettings
+	expectedSettings.Name = "overridden"
+	expectedSettings.Server.Port = 9000
+
+	// Matching files are merged in lexical order
+	for _, source := range []string{
+		"file://" + filepath.Join(dir, "*-*.*"),
+		filepath.Join(dir, "[12]0-*"),
+		"data://${SRC:" + filepath.Join(dir, "*") + "}",
+	} {
+		settings := TestSettings{}
+		err := cf.Load(cf.Options{
+			Source: source,
+			Schema: schemaJSON,
+		}, &settings)
+		if err != nil {
+			t.Fatalf("unable to load settings [source=%v] [err=%v]", source, err)
+		}
+		if !reflect.DeepEqual(settings, expectedSettings) {
+			t.Fatalf("settings mismatch [source=%v]", source)
+		}
+	}
+
+	// A pattern matching a single file
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: filepath.Join(dir, "*.json"),
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Existing files whose names contain pattern characters are loaded as is
+	writeFileAtomic(t, filepath.Join(dir, "conf[prod].json"), goodSettingsJSON)
+	writeFileAtomic(t, filepath.Join(dir, "confp.json"), `{ "name": "matched by the pattern" }`)
+	for _, source := range []string{filepath.Join(dir, "conf[prod].json"), "file://" + filepath.Join(dir, "conf[prod].json")} {
+		settings = TestSettings{}
+		err = cf.Load(cf.Options{
+			Source: source,
+			Schema: schemaJSON,
+		}, &settings)
+		if err != nil {
+			t.Fatalf("unable to load settings [source=%v] [err=%v]", source, err)
+		}
+		if !reflect.DeepEqual(settings, goodSettings) {
+			t.Fatalf("settings mismatch [source=%v]", source)
+		}
+	}
+}
+
+func TestGlobSourceNoMatches(t *testing.T) {
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	writeFileAtomic(t, filepath.Join(dir, "10-base.json"), goodSettingsJSON)
+
+	// By default, patterns must match at least one file
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Sources: []string{
+			filepath.Join(dir, "*.json"),
+		},
+		Source: filepath.Join(dir, "overrides", "*.json"),
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+
+	// Unless it is allowed
+	err = cf.Load(cf.Options{
+		Sources: []string{
+			filepath.Join(dir, "*.json"),
+		},
+		Source:         filepath.Join(dir, "overrides", "*.json"),
+		AllowEmptyGlob: true,
+		Schema:         schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+func TestWatchGlobSource(t *testing.T) {
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	writeFileAtomic(t, filepath.Join(dir, "10-base.json"), goodSettingsJSON)
+
+	// Start watching
+	settings := TestSettings{}
+	resultCh := startTestWatch(t, cf.Options{
+		Source: filepath.Join(dir, "*.json"),
+		Schema: schemaJSON,
+		Reload: cf.ReloadOptions{
+			Debounce: 50 * time.Millisecond,
+		},
+	}, &settings)
+
+	// Files not matching the pattern must be ignored
+	writeFileAtomic(t, filepath.Join(dir, "notes.txt"), "some notes")
+
+	// Add a new matching file
+	writeFileAtomic(t, filepath.Join(dir, "20-override.json"), `{ "name": "new string test" }`)
+
+	result := waitWatchResult(t, resultCh)
+	if result.err != nil {
+		t.Fatalf("unexpected reload error [err=%v]", result.err)
+	}
+	if result.settings.Name != "new string test" {
+		t.Fatalf("settings mismatch")
+	}
+}
@@ -1,10 +1,14 @@
 package go_config_reader_test
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	cf "github.com/randlabs/go-config-reader"
 )
@@ -47,3 +51,69 @@ func TestHttpSource(t *testing.T) {
 		t.Fatalf("settings mismatch")
 	}
 }
+
+func TestWatchHttpSource(t *testing.T) {
+	var mtx sync.Mutex
+	version := 1
+	notModifiedCount := 0
+
+	// Create a test http server that supports conditional requests
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		etag := fmt.Sprintf(`"v%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			notModifiedCount += 1
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusOK)
+		if version == 1 {
+			_, _ = w.Write([]byte(goodSettingsJSON))
+		} else {
+			_, _ = w.Write([]byte(strings.Replace(goodSettingsJSON, "string test", "new string test", 1)))
+		}
+	}))
+	defer svr.Close()
+
+	// Start watching
+	settings := TestSettings{}
+	resultCh := startTestWatch(t, cf.Options{
+		Source: svr.URL + "/settings",
+		Schema: schemaJSON,
+		Reload: cf.ReloadOptions{
+			Debounce:     10 * time.Millisecond,
+			PollInterval: 20 * time.Millisecond,
+			PollJitter:   10 * time.Millisecond,
+		},
+	}, &settings)
+
+	// Let the watcher poll a few times and then change the content
+	time.Sleep(200 * time.Millisecond)
+	mtx.Lock()
+	if notModifiedCount == 0 {
+		mtx.Unlock()
+		t.Fatalf("conditional requests were not sent")
+	}
+	version = 2
+	mtx.Unlock()
+
+	result := waitWatchResult(t, resultCh)
+	if result.err != nil {
+		t.Fatalf("unexpected reload error [err=%v]", result.err)
+	}
+	if result.settings.Name != "new string test" {
+		t.Fatalf("settings mismatch")
+	}
+
+	// No other changes must be delivered
+	select {
+	case <-resultCh:
+		t.Fatalf("unexpected configuration change")
+	default:
+	}
+}
@@ -0,0 +1,111 @@
+package go_config_reader_test
+
+import (
+	"reflect"
+	"testing"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+func TestLayeredSources(t *testing.T) {
+	// Load the base settings and override some of them
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Sources: []string{
+			"data://" + badSettingsJSON,
+			"data://" + goodSettingsYAML,
+		},
+		Source: `data://{ "server": { "port": 9000 } }`,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		dumpValidationErrors(t, err)
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+
+	// Check if settings are the expected
+	expectedSettings := goodSettings
+	expectedSettings.Server.Port = 9000
+	if !reflect.DeepEqual(settings, expectedSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// The forced format only applies to the main source
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Sources: []string{
+			"data://" + goodSettingsJSON,
+		},
+		Source: "data://server:\n  port: 9000\n",
+		Format: cf.FormatYAML,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		dumpValidationErrors(t, err)
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, expectedSettings) {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+func TestLayeredArrayMerge(t *testing.T) {
+	type Item struct {
+		Name  string `json:"name"`
+		Value int    `json:"value"`
+		Extra bool   `json:"extra"`
+	}
+	type ArraySettings struct {
+		Items []Item `json:"items"`
+	}
+
+	sources := []string{
+		`{ "items": [ { "name": "a", "value": 1 }, { "name": "b", "value": 2 } ] }`,
+		`{ "items": [ { "name": "b", "extra": true }, { "name": "c", "value": 3 } ] }`,
+	}
+
+	testCases := []struct {
+		strategy cf.ArrayMergeStrategy
+		expected []Item
+	}{
+		{
+			strategy: cf.ArrayMergeReplace,
+			expected: []Item{{Name: "b", Extra: true}, {Name: "c", Value: 3}},
+		},
+		{
+			strategy: cf.ArrayMergeAppend,
+			expected: []Item{{Name: "a", Value: 1}, {Name: "b", Value: 2}, {Name: "b", Extra: true}, {Name: "c", Value: 3}},
+		},
+		{
+			strategy: cf.ArrayMergeByKey,
+			expected: []Item{{Name: "a", Value: 1}, {Name: "b", Value: 2, Extra: true}, {Name: "c", Value: 3}},
+		},
+	}
+
+	for _, tc := range testCases {
+		settings := ArraySettings{}
+		err := cf.Load(cf.Options{
+			Sources:            sources,
+			ArrayMergeStrategy: tc.strategy,
+			ArrayMergeKey:      "name",
+		}, &settings)
+		if err != nil {
+			t.Fatalf("unable to load settings [err=%v]", err)
+		}
+
+		if !reflect.DeepEqual(settings.Items, tc.expected) {
+			t.Fatalf("settings mismatch [strategy=%v] [got=%+v]", tc.strategy, settings.Items)
+		}
+	}
+
+	// The merge by key strategy requires a key
+	err := cf.Load(cf.Options{
+		Sources:            sources,
+		ArrayMergeStrategy: cf.ArrayMergeByKey,
+	}, &ArraySettings{})
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+}
@@ -0,0 +1,275 @@
+package go_config_reader_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+// fakeS3 mimics the subset of the S3 API used by the loader with path-style requests and versioned objects.
+type fakeS3 struct {
+	t         *testing.T
+	mtx       sync.Mutex
+	server    *httptest.Server
+	accessKey string
+	objects   map[string][]fakeS3Object // Versions by bucket/key
+}
+
+type fakeS3Object struct {
+	content     string
+	contentType string
+}
+
+//------------------------------------------------------------------------------
+
+func TestS3Source(t *testing.T) {
+	fs := newFakeS3(t)
+	defer fs.Close()
+
+	fs.put("configs/myapp/settings.json", goodSettingsJSON, "application/json")
+	fs.put("configs/myapp/settings.yaml", goodSettingsYAML, "binary/octet-stream")
+
+	// Load configuration from objects. The format is taken from the content type or the key's extension.
+	for _, key := range []string{"myapp/settings.json", "myapp/settings.yaml"} {
+		settings := TestSettings{}
+		err := cf.Load(cf.Options{
+			Source: fs.url("configs", key),
+			Schema: schemaJSON,
+		}, &settings)
+		if err != nil {
+			t.Fatalf("unable to load settings [key=%v] [err=%v]", key, err)
+		}
+		if !reflect.DeepEqual(settings, goodSettings) {
+			t.Fatalf("settings mismatch [key=%v]", key)
+		}
+	}
+
+	// Credentials can also be taken from the environment
+	defer scopedEnvVar("AWS_ACCESS_KEY_ID")()
+	defer scopedEnvVar("AWS_SECRET_ACCESS_KEY")()
+	_ = os.Setenv("AWS_ACCESS_KEY_ID", fs.accessKey)
+	_ = os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: "s3://configs/myapp/settings.json?region=us-east-1&path_style=true&endpoint=" + fs.server.URL,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Wrong credentials must fail
+	err = cf.Load(cf.Options{
+		Source: strings.Replace(fs.url("configs", "myapp/settings.json"), fs.accessKey, "WRONGKEY", 1),
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+
+	// And also missing objects
+	err = cf.Load(cf.Options{
+		Source: fs.url("configs", "myapp/missing.json"),
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+}
+
+func TestS3SourceVersion(t *testing.T) {
+	fs := newFakeS3(t)
+	defer fs.Close()
+
+	fs.put("configs/settings.json", goodSettingsJSON, "application/json")
+	fs.put("configs/settings.json", strings.Replace(goodSettingsJSON, "string test", "new string test", 1), "application/json")
+
+	// Pin the first version
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: fs.url("configs", "settings.json") + "&version_id=1",
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+func TestWatchS3Source(t *testing.T) {
+	fs := newFakeS3(t)
+	defer fs.Close()
+
+	fs.put("configs/settings.json", goodSettingsJSON, "application/json")
+
+	// Start watching
+	settings := TestSettings{}
+	resultCh := startTestWatch(t, cf.Options{
+		Source: fs.url("configs", "settings.json"),
+		Schema: schemaJSON,
+		Reload: cf.ReloadOptions{
+			Debounce:     10 * time.Millisecond,
+			PollInterval: 50 * time.Millisecond,
+		},
+	}, &settings)
+
+	// Upload a new version
+	fs.put("configs/settings.json", strings.Replace(goodSettingsJSON, "string test", "new string test", 1), "application/json")
+
+	result := waitWatchResult(t, resultCh)
+	if result.err != nil {
+		t.Fatalf("unexpected reload error [err=%v]", result.err)
+	}
+	if result.settings.Name != "new string test" {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+func TestWatchS3SourceMetadata(t *testing.T) {
+	fs := newFakeS3(t)
+	defer fs.Close()
+
+	fs.put("configs/settings.json", goodSettingsJSON, "application/json")
+
+	// Start watching
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	var w *cf.Watcher
+	var err error
+
+	sourcesCh := make(chan []cf.SourceInfo, 10)
+	settings := TestSettings{}
+	w, err = cf.Watch(ctx, cf.Options{
+		Source: fs.url("configs", "settings.json"),
+		Schema: schemaJSON,
+		Reload: cf.ReloadOptions{
+			Debounce:     10 * time.Millisecond,
+			PollInterval: 50 * time.Millisecond,
+		},
+	}, &settings, func(_ interface{}, err error) {
+		if err == nil {
+			sourcesCh <- w.Sources()
+		}
+	})
+	if err != nil {
+		t.Fatalf("unable to watch settings [err=%v]", err)
+	}
+	defer w.Wait()
+	defer cancelCtx()
+
+	// checkSources verifies the object version and ETag reported for the loaded object
+	checkSources := func(sources []cf.SourceInfo, version string, content string) {
+		hash := md5.Sum([]byte(content))
+		if len(sources) != 1 || sources[0].Source != fs.url("configs", "settings.json") ||
+			sources[0].Format != cf.FormatJSON || sources[0].Metadata["version_id"] != version ||
+			sources[0].Metadata["etag"] != `"`+hex.EncodeToString(hash[:])+`"` {
+			t.Fatalf("unexpected sources [sources=%v]", sources)
+		}
+	}
+	checkSources(w.Sources(), "1", goodSettingsJSON)
+
+	// Upload a new version. The callback must see the new metadata.
+	newContent := strings.Replace(goodSettingsJSON, "string test", "new string test", 1)
+	fs.put("configs/settings.json", newContent, "application/json")
+
+	select {
+	case sources := <-sourcesCh:
+		checkSources(sources, "2", newContent)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout waiting for a configuration change")
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	fs := &fakeS3{
+		t:         t,
+		accessKey: "AKIDEXAMPLE",
+		objects:   make(map[string][]fakeS3Object),
+	}
+	fs.server = httptest.NewServer(http.HandlerFunc(fs.handle))
+	return fs
+}
+
+func (fs *fakeS3) Close() {
+	fs.server.Close()
+}
+
+// url returns an s3 source url that uses explicit keys and the fake server as the endpoint
+func (fs *fakeS3) url(bucket string, key string) string {
+	return "s3://" + bucket + "/" + key + "?region=us-east-1&path_style=true&endpoint=" + fs.server.URL +
+		"&access_key=" + fs.accessKey + "&secret_key=secret"
+}
+
+// put stores a new version of an object
+func (fs *fakeS3) put(path string, content string, contentType string) {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	fs.objects[path] = append(fs.objects[path], fakeS3Object{
+		content:     content,
+		contentType: contentType,
+	})
+}
+
+func (fs *fakeS3) handle(w http.ResponseWriter, r *http.Request) {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	// Requests must be signed with the expected access key. The signature itself is not verified.
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+fs.accessKey+"/") {
+		fs.writeError(w, http.StatusForbidden, "InvalidAccessKeyId")
+		return
+	}
+
+	versions, ok := fs.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok || (r.Method != "GET" && r.Method != "HEAD") {
+		fs.writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	version := len(versions)
+	if v := r.URL.Query().Get("versionId"); len(v) > 0 {
+		version, _ = strconv.Atoi(v)
+		if version < 1 || version > len(versions) {
+			fs.writeError(w, http.StatusNotFound, "NoSuchVersion")
+			return
+		}
+	}
+	object := versions[version-1]
+
+	hash := md5.Sum([]byte(object.content))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:])+`"`)
+	w.Header().Set("x-amz-version-id", strconv.Itoa(version))
+	w.Header().Set("Content-Type", object.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(object.content)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == "GET" {
+		_, _ = w.Write([]byte(object.content))
+	}
+}
+
+func (fs *fakeS3) writeError(w http.ResponseWriter, statusCode int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>` + code + `</Code></Error>`))
+}
@@ -0,0 +1,93 @@
+package go_config_reader_test
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+// NOTE: The standard input is read only once per process so all the checks are done in a single test.
+func TestStdinSource(t *testing.T) {
+	// Replace the standard input with a pipe
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unable to create pipe [err=%v]", err)
+	}
+	origStdin := os.Stdin
+	os.Stdin = r
+	defer func() {
+		os.Stdin = origStdin
+		_ = r.Close()
+	}()
+
+	// Loading must honor the context while waiting for data
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelCtx()
+
+	settings := TestSettings{}
+	err = cf.Load(cf.Options{
+		Source:  "-",
+		Schema:  schemaJSON,
+		Context: ctx,
+	}, &settings)
+	if err == nil {
+		t.Fatalf("load succeeded while waiting for the standard input")
+	}
+
+	// Send the settings and close the pipe
+	_, err = w.Write([]byte(goodSettingsJSON))
+	if err != nil {
+		t.Fatalf("unable to write good settings json [err=%v]", err)
+	}
+	_ = w.Close()
+
+	// Load configuration from the standard input
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: "-",
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Later loads get the same content
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: "stdin://?format=json",
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// And the source can also be passed in the command line
+	origArgs := os.Args
+	os.Args = []string{origArgs[0], "--settings", "-"}
+	defer func() {
+		os.Args = origArgs
+	}()
+
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+}
@@ -0,0 +1,180 @@
+package go_config_reader_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+type dbCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+//------------------------------------------------------------------------------
+
+func TestWatchDynamicVaultSecret(t *testing.T) {
+	fv := newFakeVault(t)
+	defer fv.Close()
+
+	// Credentials last two seconds and they can be renewed for up to four
+	fv.addDynamic("database/creds/my-role", 2, 4)
+
+	// Start watching
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	resultCh := make(chan *dbCredentials, 10)
+	errCh := make(chan error, 10)
+	settings := dbCredentials{}
+	w, err := cf.Watch(ctx, cf.Options{
+		Source: fv.url("database/creds/my-role"),
+		Reload: cf.ReloadOptions{
+			Debounce: 10 * time.Millisecond,
+		},
+	}, &settings, func(settings interface{}, err error) {
+		if err != nil {
+			errCh <- err
+		} else {
+			resultCh <- settings.(*dbCredentials)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unable to watch settings [err=%v]", err)
+	}
+	if settings.Username != "user-1" || settings.Password != "pass-1" {
+		t.Fatalf("settings mismatch")
+	}
+
+	// The lease is renewed until it reaches its maximum time to live and then new credentials are issued
+	select {
+	case newSettings := <-resultCh:
+		if newSettings.Username != "user-2" || newSettings.Password != "pass-2" {
+			t.Fatalf("settings mismatch")
+		}
+	case err = <-errCh:
+		t.Fatalf("unexpected reload error [err=%v]", err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout while waiting for new credentials")
+	}
+	if fv.leaseRenewals("database/creds/my-role/1") == 0 {
+		t.Fatalf("lease was not renewed")
+	}
+
+	// Stop watching. The lease of the credentials in use must be revoked.
+	cancelCtx()
+	w.Wait()
+
+	if !fv.leaseRevoked("database/creds/my-role/2") {
+		t.Fatalf("lease was not revoked")
+	}
+}
+
+func TestWatchVaultLeaseRelease(t *testing.T) {
+	fv := newFakeVault(t)
+	defer fv.Close()
+
+	// Every read returns the same credentials with a new lease
+	fv.addLeased("database/creds/my-role", 3600)
+
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// Layered sources are merged, so changes in the formatting of a layer do not change the settings
+	baseFilename := filepath.Join(dir, "base.json")
+	writeFileAtomic(t, baseFilename, `{ "password": "pass-a" }`)
+
+	filename := filepath.Join(dir, "settings.json")
+	content := `{ "username": "${SRC:` + fv.url("database/creds/my-role") + `&key=username}"%v }`
+	writeFileAtomic(t, filename, fmt.Sprintf(content, ""))
+
+	// Start watching
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	resultCh := make(chan *dbCredentials, 10)
+	errCh := make(chan error, 10)
+	settings := dbCredentials{}
+	w, err := cf.Watch(ctx, cf.Options{
+		Sources: []string{baseFilename},
+		Source:  filename,
+		Reload: cf.ReloadOptions{
+			Debounce: 10 * time.Millisecond,
+		},
+	}, &settings, func(settings interface{}, err error) {
+		if err != nil {
+			errCh <- err
+		} else {
+			resultCh <- settings.(*dbCredentials)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unable to watch settings [err=%v]", err)
+	}
+	defer w.Wait()
+	defer cancelCtx()
+
+	// waitLeases waits until the given number of leases were issued and only one of them remains active
+	waitLeases := func(issued int) {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+			if fv.issuedLeases("database/creds/my-role") >= issued && fv.activeLeases() == 1 {
+				return
+			}
+			select {
+			case err = <-errCh:
+				t.Fatalf("unexpected reload error [err=%v]", err)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		t.Fatalf("unexpected lease count [issued=%v] [active=%v]", fv.issuedLeases("database/creds/my-role"),
+			fv.activeLeases())
+	}
+
+	// A reload that does not change the settings must revoke the lease it obtained
+	writeFileAtomic(t, baseFilename, "{\n  \"password\": \"pass-a\"\n}\n")
+	waitLeases(2)
+
+	// A reload that changes the settings must revoke the lease of the replaced ones
+	writeFileAtomic(t, filename, fmt.Sprintf(content, `, "password": "pass-b"`))
+	select {
+	case newSettings := <-resultCh:
+		if newSettings.Username != "user" || newSettings.Password != "pass-b" {
+			t.Fatalf("settings mismatch")
+		}
+	case err = <-errCh:
+		t.Fatalf("unexpected reload error [err=%v]", err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout waiting for a configuration change")
+	}
+	waitLeases(3)
+}
+
+func TestLoadVaultLeaseRelease(t *testing.T) {
+	fv := newFakeVault(t)
+	defer fv.Close()
+
+	fv.addLeased("database/creds/my-role", 3600)
+
+	// The credentials do not match the schema so the lease obtained while loading them must be revoked
+	settings := dbCredentials{}
+	err := cf.Load(cf.Options{
+		Source: fv.url("database/creds/my-role"),
+		Schema: schemaJSON,
+	}, &settings)
+	if err == nil {
+		t.Fatalf("invalid settings were accepted")
+	}
+	if fv.issuedLeases("database/creds/my-role") != 1 || fv.activeLeases() != 0 {
+		t.Fatalf("unexpected lease count [issued=%v] [active=%v]", fv.issuedLeases("database/creds/my-role"),
+			fv.activeLeases())
+	}
+}
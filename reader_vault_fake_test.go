@@ -0,0 +1,494 @@
+package go_config_reader_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// fakeVault mimics the subset of the Hashicorp Vault API used by the loader.
+type fakeVault struct {
+	t         *testing.T
+	mtx       sync.Mutex
+	server    *httptest.Server
+	token     string
+	secrets   map[string]*fakeVaultSecret
+	mounts    map[string]int // KV engine version by mount path
+	logins    int
+	loginTTL  int                  // Lifetime in seconds of the tokens issued by logins. Defaults to one hour.
+	tokens    map[string]time.Time // Expiration of the tokens issued by logins
+	namespace string               // Namespace requests must include, if any
+	transit   map[string]bool      // Transit keys by "mount/key-name"
+	dynamic   map[string]*fakeVaultDynamic
+	leases    map[string]*fakeVaultLease
+}
+
+type fakeVaultSecret struct {
+	versions      []map[string]interface{}
+	leaseID       string
+	leaseDuration int
+}
+
+// fakeVaultDynamic issues new credentials, each one with its own lease, every time it is read
+type fakeVaultDynamic struct {
+	ttl    int
+	maxTTL int
+	issued int
+	fixed  bool // Set to return the same credentials on every read
+}
+
+type fakeVaultLease struct {
+	issuedAt time.Time
+	maxTTL   int
+	renewals int
+	revoked  bool
+}
+
+//------------------------------------------------------------------------------
+
+func newFakeVault(t *testing.T) *fakeVault {
+	fv := &fakeVault{
+		t:       t,
+		token:   "root",
+		secrets: make(map[string]*fakeVaultSecret),
+		mounts:  make(map[string]int),
+		transit: make(map[string]bool),
+		dynamic: make(map[string]*fakeVaultDynamic),
+		leases:  make(map[string]*fakeVaultLease),
+		tokens:  make(map[string]time.Time),
+	}
+	fv.server = httptest.NewServer(http.HandlerFunc(fv.handle))
+	return fv
+}
+
+// newFakeVaultTLS creates a TLS-terminated fake vault server. If a client CA pool is provided, clients must
+// present a certificate signed by it.
+func newFakeVaultTLS(t *testing.T, clientCAs *x509.CertPool) *fakeVault {
+	fv := &fakeVault{
+		t:       t,
+		token:   "root",
+		secrets: make(map[string]*fakeVaultSecret),
+		mounts:  make(map[string]int),
+		transit: make(map[string]bool),
+		dynamic: make(map[string]*fakeVaultDynamic),
+		leases:  make(map[string]*fakeVaultLease),
+		tokens:  make(map[string]time.Time),
+	}
+	fv.server = httptest.NewUnstartedServer(http.HandlerFunc(fv.handle))
+	fv.server.Config.ErrorLog = log.New(ioutil.Discard, "", 0) // Silence expected handshake errors
+	if clientCAs != nil {
+		fv.server.TLS = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+		}
+	}
+	fv.server.StartTLS()
+	return fv
+}
+
+func (fv *fakeVault) Close() {
+	fv.server.Close()
+}
+
+// url returns a vault source url for the given path
+func (fv *fakeVault) url(path string) string {
+	scheme := "vault://"
+	if fv.server.TLS != nil {
+		scheme = "vaults://"
+	}
+	return scheme + fv.address() + "?path=" + path + "&token=" + fv.token
+}
+
+// writeKV2 stores a new version of a KV v2 secret. The path must include the data/ segment.
+func (fv *fakeVault) writeKV2(path string, data string) {
+	fv.writeSecret(path, data, 2)
+}
+
+// writeKV1 stores a KV v1 secret
+func (fv *fakeVault) writeKV1(path string, data string) {
+	fv.writeSecret(path, data, 1)
+}
+
+func (fv *fakeVault) writeSecret(path string, data string, kvVersion int) {
+	var value map[string]interface{}
+
+	err := json.Unmarshal([]byte(data), &value)
+	if err != nil {
+		fv.t.Fatalf("unable to decode secret [path=%v] [err=%v]", path, err)
+	}
+
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	if mount, _ := fv.mountOf(path); len(mount) == 0 {
+		fv.mounts[strings.SplitN(path, "/", 2)[0]+"/"] = kvVersion
+	}
+
+	secret, ok := fv.secrets[path]
+	if !ok {
+		secret = &fakeVaultSecret{}
+		fv.secrets[path] = secret
+	}
+	if kvVersion == 2 {
+		secret.versions = append(secret.versions, value)
+	} else {
+		secret.versions = []map[string]interface{}{value}
+	}
+}
+
+// addMount creates a KV mount at the given path, which must end with a slash. Mounts can be nested.
+func (fv *fakeVault) addMount(path string, kvVersion int) {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	fv.mounts[path] = kvVersion
+}
+
+// mountOf returns the longest mount containing the path and its KV engine version
+func (fv *fakeVault) mountOf(path string) (string, int) {
+	mount := ""
+	for m := range fv.mounts {
+		if len(m) > len(mount) && strings.HasPrefix(path, m) {
+			mount = m
+		}
+	}
+	return mount, fv.mounts[mount]
+}
+
+// encryptTransit returns a ciphertext the fake transit engine decrypts using the given key. The fake does not really
+// encrypt, it just encodes the plaintext.
+func (fv *fakeVault) encryptTransit(mount string, keyName string, plaintext string) string {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	fv.transit[mount+"/"+keyName] = true
+	return "vault:v1:" + base64.StdEncoding.EncodeToString([]byte(plaintext))
+}
+
+// addDynamic creates a dynamic secrets path. Each read returns the username "user-N" and the password "pass-N",
+// where N is the number of issued credentials, along with the lease "path/N".
+func (fv *fakeVault) addDynamic(path string, ttl int, maxTTL int) {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	fv.dynamic[path] = &fakeVaultDynamic{
+		ttl:    ttl,
+		maxTTL: maxTTL,
+	}
+}
+
+// addLeased creates a secret that returns the same credentials, with a new lease, every time it is read
+func (fv *fakeVault) addLeased(path string, ttl int) {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	fv.dynamic[path] = &fakeVaultDynamic{
+		ttl:    ttl,
+		maxTTL: ttl,
+		fixed:  true,
+	}
+}
+
+// activeLeases returns the number of issued leases that were not revoked
+func (fv *fakeVault) activeLeases() int {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	count := 0
+	for _, lease := range fv.leases {
+		if !lease.revoked {
+			count += 1
+		}
+	}
+	return count
+}
+
+// issuedLeases returns the number of leases issued for a secret
+func (fv *fakeVault) issuedLeases(path string) int {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	if dynamic, ok := fv.dynamic[path]; ok {
+		return dynamic.issued
+	}
+	return 0
+}
+
+// leaseRenewals returns the number of times a lease was renewed
+func (fv *fakeVault) leaseRenewals(leaseID string) int {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	if lease, ok := fv.leases[leaseID]; ok {
+		return lease.renewals
+	}
+	return 0
+}
+
+// leaseRevoked returns true if the lease was revoked
+func (fv *fakeVault) leaseRevoked(leaseID string) bool {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	if lease, ok := fv.leases[leaseID]; ok {
+		return lease.revoked
+	}
+	return false
+}
+
+// setLease assigns a lease to a secret
+func (fv *fakeVault) setLease(path string, leaseID string, leaseDuration int) {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	secret, ok := fv.secrets[path]
+	if !ok {
+		fv.t.Fatalf("secret not found [path=%v]", path)
+	}
+	secret.leaseID = leaseID
+	secret.leaseDuration = leaseDuration
+}
+
+func (fv *fakeVault) handle(w http.ResponseWriter, r *http.Request) {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+	if r.Header.Get("X-Vault-Namespace") != fv.namespace {
+		fv.writeResponse(w, http.StatusNotFound, map[string]interface{}{
+			"errors": []string{"namespace not found"},
+		})
+		return
+	}
+
+	// Login endpoints do not require a token
+	if strings.HasPrefix(path, "auth/") && r.Method != "GET" {
+		fv.handleLogin(w, r, path)
+		return
+	}
+
+	if !fv.isValidToken(r.Header.Get("X-Vault-Token")) {
+		fv.writeResponse(w, http.StatusForbidden, map[string]interface{}{
+			"errors": []string{"permission denied"},
+		})
+		return
+	}
+
+	switch r.Method {
+	case "POST", "PUT":
+		if path == "sys/leases/renew" || path == "sys/leases/revoke" {
+			fv.handleLease(w, r, path)
+			return
+		}
+		if parts := strings.SplitN(path, "/decrypt/", 2); len(parts) == 2 && fv.transit[parts[0]+"/"+parts[1]] {
+			var body map[string]interface{}
+
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			ciphertext, _ := body["ciphertext"].(string)
+			if strings.HasPrefix(ciphertext, "vault:v1:") {
+				fv.writeResponse(w, http.StatusOK, map[string]interface{}{
+					"data": map[string]interface{}{
+						"plaintext": strings.TrimPrefix(ciphertext, "vault:v1:"),
+					},
+				})
+				return
+			}
+			fv.writeResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"errors": []string{"invalid ciphertext"},
+			})
+			return
+		}
+
+	case "GET":
+		if strings.HasPrefix(path, "sys/internal/ui/mounts/") {
+			if mount, kvVersion := fv.mountOf(strings.TrimPrefix(path, "sys/internal/ui/mounts/")); len(mount) > 0 {
+				fv.writeResponse(w, http.StatusOK, map[string]interface{}{
+					"data": map[string]interface{}{
+						"path": mount,
+						"type": "kv",
+						"options": map[string]interface{}{
+							"version": strconv.Itoa(kvVersion),
+						},
+					},
+				})
+				return
+			}
+		} else if dynamic, ok := fv.dynamic[path]; ok {
+			dynamic.issued += 1
+			leaseID := path + "/" + strconv.Itoa(dynamic.issued)
+			fv.leases[leaseID] = &fakeVaultLease{
+				issuedAt: time.Now(),
+				maxTTL:   dynamic.maxTTL,
+			}
+			suffix := "-" + strconv.Itoa(dynamic.issued)
+			if dynamic.fixed {
+				suffix = ""
+			}
+			fv.writeResponse(w, http.StatusOK, map[string]interface{}{
+				"lease_id":       leaseID,
+				"lease_duration": dynamic.ttl,
+				"renewable":      true,
+				"data": map[string]interface{}{
+					"username": "user" + suffix,
+					"password": "pass" + suffix,
+				},
+			})
+			return
+		} else if secret, ok := fv.secrets[path]; ok {
+			version := len(secret.versions)
+			if v := r.URL.Query().Get("version"); len(v) > 0 {
+				version, _ = strconv.Atoi(v)
+			}
+			if version >= 1 && version <= len(secret.versions) {
+				data := map[string]interface{}{
+					"data": secret.versions[version-1],
+					"metadata": map[string]interface{}{
+						"version": version,
+					},
+				}
+				if _, kvVersion := fv.mountOf(path); kvVersion == 1 {
+					data = secret.versions[0]
+				}
+				fv.writeResponse(w, http.StatusOK, map[string]interface{}{
+					"lease_id":       secret.leaseID,
+					"lease_duration": secret.leaseDuration,
+					"renewable":      len(secret.leaseID) > 0,
+					"data":           data,
+				})
+				return
+			}
+		}
+	}
+
+	fv.writeResponse(w, http.StatusNotFound, map[string]interface{}{
+		"errors": []string{},
+	})
+}
+
+// handleLease renews or revokes the leases of dynamic secrets. Renewals are capped by the maximum time to live.
+func (fv *fakeVault) handleLease(w http.ResponseWriter, r *http.Request, path string) {
+	var body map[string]interface{}
+
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	leaseID, _ := body["lease_id"].(string)
+
+	lease, ok := fv.leases[leaseID]
+	if !ok || lease.revoked {
+		fv.writeResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []string{"lease not found"},
+		})
+		return
+	}
+
+	if path == "sys/leases/revoke" {
+		lease.revoked = true
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	increment, _ := body["increment"].(float64)
+	ttl := int(increment)
+	if remaining := lease.maxTTL - int(time.Since(lease.issuedAt)/time.Second); remaining < ttl {
+		ttl = remaining
+	}
+	if ttl <= 0 {
+		fv.writeResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []string{"lease expired"},
+		})
+		return
+	}
+	lease.renewals += 1
+	fv.writeResponse(w, http.StatusOK, map[string]interface{}{
+		"lease_id":       leaseID,
+		"lease_duration": ttl,
+		"renewable":      true,
+	})
+}
+
+// handleLogin accepts fixed credentials for the AppRole, Kubernetes and userpass authentication methods
+func (fv *fakeVault) handleLogin(w http.ResponseWriter, r *http.Request, path string) {
+	var body map[string]interface{}
+
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	ok := false
+	switch path {
+	case "auth/approle/login":
+		ok = body["role_id"] == "my-role-id" && body["secret_id"] == "my-secret-id"
+	case "auth/kubernetes/login":
+		ok = body["role"] == "my-role" && body["jwt"] == "my-jwt"
+	case "auth/userpass/login/my-user":
+		ok = body["password"] == "my-password"
+	}
+	if !ok {
+		fv.writeResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []string{"invalid credentials"},
+		})
+		return
+	}
+
+	ttl := fv.loginTTL
+	if ttl == 0 {
+		ttl = 3600
+	}
+
+	fv.logins += 1
+	token := "login-" + strconv.Itoa(fv.logins)
+	fv.tokens[token] = time.Now().Add(time.Duration(ttl) * time.Second)
+	fv.writeResponse(w, http.StatusOK, map[string]interface{}{
+		"auth": map[string]interface{}{
+			"client_token":   token,
+			"lease_duration": ttl,
+			"renewable":      true,
+		},
+	})
+}
+
+// isValidToken checks if the token is the root one or an unexpired one issued by a login
+func (fv *fakeVault) isValidToken(token string) bool {
+	if token == fv.token {
+		return true
+	}
+	expiresAt, ok := fv.tokens[token]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// setLoginTTL sets the lifetime in seconds of the tokens issued by later logins
+func (fv *fakeVault) setLoginTTL(ttl int) {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	fv.loginTTL = ttl
+}
+
+// loginCount returns the number of successful logins
+func (fv *fakeVault) loginCount() int {
+	fv.mtx.Lock()
+	defer fv.mtx.Unlock()
+
+	return fv.logins
+}
+
+// address returns the server address without the scheme
+func (fv *fakeVault) address() string {
+	return strings.TrimPrefix(strings.TrimPrefix(fv.server.URL, "http://"), "https://")
+}
+
+func (fv *fakeVault) writeResponse(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -121,3 +121,64 @@ func buildVaultUrl(key string) string {
 func key2path(key string) string {
 	return "secret/data/go_reader_test/" + key
 }
+
+func TestWatchVaultSource(t *testing.T) {
+	fv := newFakeVault(t)
+	defer fv.Close()
+
+	fv.writeKV2("secret/data/settings", goodSettingsJSON)
+
+	// Start watching
+	settings := TestSettings{}
+	resultCh := startTestWatch(t, cf.Options{
+		Source: fv.url("secret/data/settings"),
+		Schema: schemaJSON,
+		Reload: cf.ReloadOptions{
+			Debounce:     10 * time.Millisecond,
+			PollInterval: 20 * time.Millisecond,
+		},
+	}, &settings)
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Rotate the secret
+	fv.writeKV2("secret/data/settings", strings.Replace(goodSettingsJSON, "some-api-access-token", "new-token", 1))
+
+	result := waitWatchResult(t, resultCh)
+	if result.err != nil {
+		t.Fatalf("unexpected reload error [err=%v]", result.err)
+	}
+	if result.settings.Node.ApiToken != "new-token" {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+func TestWatchLeasedVaultSource(t *testing.T) {
+	fv := newFakeVault(t)
+	defer fv.Close()
+
+	fv.writeKV2("secret/data/settings", goodSettingsJSON)
+	fv.setLease("secret/data/settings", "secret/data/settings/lease1", 1)
+
+	// Start watching
+	settings := TestSettings{}
+	resultCh := startTestWatch(t, cf.Options{
+		Source: fv.url("secret/data/settings"),
+		Schema: schemaJSON,
+		Reload: cf.ReloadOptions{
+			Debounce: 10 * time.Millisecond,
+		},
+	}, &settings)
+
+	// Change the secret. It must be read again before the lease expires even if the poll interval is long.
+	fv.writeKV2("secret/data/settings", strings.Replace(goodSettingsJSON, "some-api-access-token", "new-token", 1))
+
+	result := waitWatchResult(t, resultCh)
+	if result.err != nil {
+		t.Fatalf("unexpected reload error [err=%v]", result.err)
+	}
+	if result.settings.Node.ApiToken != "new-token" {
+		t.Fatalf("settings mismatch")
+	}
+}
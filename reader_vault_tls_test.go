@@ -0,0 +1,214 @@
+package go_config_reader_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+func TestVaultTLS(t *testing.T) {
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// Create a client certificate
+	clientCert, clientCertFile, clientKeyFile := createClientCertificate(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	fv := newFakeVaultTLS(t, clientCAs)
+	defer fv.Close()
+
+	fv.writeKV2("secret/data/settings", goodSettingsJSON)
+
+	// Save the server certificate to use it as the CA
+	caCertFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caCertFile, "CERTIFICATE", fv.server.Certificate().Raw)
+
+	// Without the CA, the server certificate cannot be verified. Avoid retrying requests that are expected to fail.
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: fv.url("secret/settings"),
+		Vault: cf.VaultOptions{
+			ClientCert: clientCertFile,
+			ClientKey:  clientKeyFile,
+			MaxRetries: -1,
+		},
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+
+	// Without the client certificate, the server must reject the connection
+	err = cf.Load(cf.Options{
+		Source: fv.url("secret/settings") + "&ca_cert=" + caCertFile,
+		Vault: cf.VaultOptions{
+			MaxRetries: -1,
+		},
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+
+	// Now set everything, mixing url and options settings
+	err = cf.Load(cf.Options{
+		Source: fv.url("secret/settings") + "&ca_cert=" + caCertFile + "&tls_server_name=example.com",
+		Schema: schemaJSON,
+		Vault: cf.VaultOptions{
+			ClientCert: clientCertFile,
+			ClientKey:  clientKeyFile,
+		},
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// And a wrong server name must fail
+	err = cf.Load(cf.Options{
+		Source: fv.url("secret/settings") + "&ca_cert=" + caCertFile + "&tls_server_name=vault.invalid",
+		Vault: cf.VaultOptions{
+			ClientCert: clientCertFile,
+			ClientKey:  clientKeyFile,
+			MaxRetries: -1,
+		},
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+}
+
+func TestVaultNamespace(t *testing.T) {
+	fv := newFakeVault(t)
+	defer fv.Close()
+
+	fv.namespace = "team-a"
+	fv.writeKV2("secret/data/settings", goodSettingsJSON)
+
+	// Load configuration from the namespace set in the options
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: fv.url("secret/settings"),
+		Schema: schemaJSON,
+		Vault: cf.VaultOptions{
+			Namespace: "team-a",
+		},
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// The url parameter has preference
+	err = cf.Load(cf.Options{
+		Source: fv.url("secret/settings") + "&namespace=team-b",
+		Vault: cf.VaultOptions{
+			Namespace: "team-a",
+		},
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+}
+
+func TestVaultIgnoresEnvironment(t *testing.T) {
+	// Save test environment variables and restore on exit
+	defer scopedEnvVar("VAULT_NAMESPACE")()
+	defer scopedEnvVar("VAULT_TLS_SERVER_NAME")()
+
+	dir := createTempDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	fv := newFakeVaultTLS(t, nil)
+	defer fv.Close()
+
+	fv.writeKV2("secret/data/settings", goodSettingsJSON)
+
+	caCertFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caCertFile, "CERTIFICATE", fv.server.Certificate().Raw)
+
+	// Connection settings must only come from the url and the options
+	_ = os.Setenv("VAULT_NAMESPACE", "team-a")
+	_ = os.Setenv("VAULT_TLS_SERVER_NAME", "vault.invalid")
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: fv.url("secret/settings") + "&ca_cert=" + caCertFile,
+		Schema: schemaJSON,
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func createClientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key [err=%v]", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "go-config-reader-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate [err=%v]", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unable to parse certificate [err=%v]", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to encode key [err=%v]", err)
+	}
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+
+	return cert, certFile, keyFile
+}
+
+func writePEM(t *testing.T, filename string, blockType string, der []byte) {
+	err := ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{
+		Type:  blockType,
+		Bytes: der,
+	}), 0600)
+	if err != nil {
+		t.Fatalf("unable to save pem file [err=%v]", err)
+	}
+}
@@ -0,0 +1,70 @@
+package go_config_reader_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	cf "github.com/randlabs/go-config-reader"
+)
+
+//------------------------------------------------------------------------------
+
+func TestVaultTransit(t *testing.T) {
+	fv := newFakeVault(t)
+	defer fv.Close()
+
+	// Replace the mongodb password with an encrypted value
+	ciphertext := fv.encryptTransit("transit", "config-key", "pass")
+	modifiedSettingsJSON := strings.Replace(goodSettingsJSON, "user:pass@", "user:${VTRANSIT:config-key:"+ciphertext+"}@", 1)
+
+	settings := TestSettings{}
+	err := cf.Load(cf.Options{
+		Source: "data://" + modifiedSettingsJSON,
+		Schema: schemaJSON,
+		Vault: cf.VaultOptions{
+			Address: fv.server.URL,
+			Token:   fv.token,
+		},
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// Now use a custom mount and get the key name from an embedded tag
+	ciphertext = fv.encryptTransit("secrets/transit", "other-key", "pass")
+	modifiedSettingsJSON = strings.Replace(goodSettingsJSON, "user:pass@", "user:${VTRANSIT:${SRC:data://other-key}:"+ciphertext+"}@", 1)
+
+	settings = TestSettings{}
+	err = cf.Load(cf.Options{
+		Source: "data://" + modifiedSettingsJSON,
+		Schema: schemaJSON,
+		Vault: cf.VaultOptions{
+			Address:      fv.server.URL,
+			Token:        fv.token,
+			TransitMount: "/secrets/transit/",
+		},
+	}, &settings)
+	if err != nil {
+		t.Fatalf("unable to load settings [err=%v]", err)
+	}
+	if !reflect.DeepEqual(settings, goodSettings) {
+		t.Fatalf("settings mismatch")
+	}
+
+	// An unknown key must fail
+	modifiedSettingsJSON = strings.Replace(goodSettingsJSON, "user:pass@", "user:${VTRANSIT:wrong-key:"+ciphertext+"}@", 1)
+	err = cf.Load(cf.Options{
+		Source: "data://" + modifiedSettingsJSON,
+		Vault: cf.VaultOptions{
+			Address: fv.server.URL,
+			Token:   fv.token,
+		},
+	}, &settings)
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+}
@@ -0,0 +1,78 @@
+package go_config_reader
+
+import (
+	"context"
+)
+
+// -----------------------------------------------------------------------------
+
+type sessionContextKey struct{}
+type loadedSourceContextKey struct{}
+
+// session holds the state shared by all the loaders involved in a single load operation.
+type session struct {
+	options     *Options
+	sources     []*loadedSource
+	vaultTokens map[string]vaultToken
+	vaultMounts map[string]*vaultMount
+}
+
+// loadedSource holds the information a loader reports about the source being loaded.
+type loadedSource struct {
+	loader   SourceLoader
+	source   string
+	format   Format
+	metadata map[string]string // Loader specific information exposed through Watcher.Sources
+	state    interface{}       // Loader specific data used to detect changes
+}
+
+// sourceWatcher is implemented by loaders able to detect changes in the sources they load. The watch method
+// must call notify each time a change is detected and return when the context is done.
+type sourceWatcher interface {
+	watch(ctx context.Context, ls *loadedSource, notify func()) error
+}
+
+// sourceReleaser is implemented by loaders that hold resources, like leases, on the sources they load. The release
+// method is called when a watcher stops, when a load operation fails and when a reloaded configuration is discarded or
+// replaces the previous one.
+type sourceReleaser interface {
+	release(ls *loadedSource)
+}
+
+// -----------------------------------------------------------------------------
+
+func newSession(options *Options) *session {
+	return &session{
+		options:     options,
+		sources:     make([]*loadedSource, 0),
+		vaultTokens: make(map[string]vaultToken),
+		vaultMounts: make(map[string]*vaultMount),
+	}
+}
+
+// release frees the resources held by the loaded sources
+func (s *session) release() {
+	for _, ls := range s.sources {
+		if sr, ok := ls.loader.(sourceReleaser); ok {
+			sr.release(ls)
+		}
+	}
+}
+
+func withSession(ctx context.Context, s *session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+func getSession(ctx context.Context) *session {
+	s, _ := ctx.Value(sessionContextKey{}).(*session)
+	return s
+}
+
+func withLoadedSource(ctx context.Context, ls *loadedSource) context.Context {
+	return context.WithValue(ctx, loadedSourceContextKey{}, ls)
+}
+
+func getLoadedSource(ctx context.Context) *loadedSource {
+	ls, _ := ctx.Value(loadedSourceContextKey{}).(*loadedSource)
+	return ls
+}
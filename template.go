@@ -18,9 +18,23 @@ const (
 
 // -----------------------------------------------------------------------------
 
-func expandVars(ctx context.Context, data []byte, depth int) ([]byte, error) {
+// expandDocument replaces the macros found in a configuration document in the given format and unescapes the
+// literal ones
+func expandDocument(ctx context.Context, data []byte, format Format) ([]byte, error) {
+	return expandVars(ctx, data, documentSyntax(data, format), 1, true, true)
+}
+
+// expandVars replaces the macros found in data. If document is set, data is a configuration document written in
+// the given syntax and the replacements of macros located inside string literals are escaped, else they are
+// inserted as is unless a mode is specified. If unescape is set, escaped macros found in data, but not in the
+// replacements, are emitted as literal ones. Only documents, and not values like environment variables or file
+// contents, are unescaped.
+func expandVars(
+	ctx context.Context, data []byte, syntax preprocessor.Syntax, depth int, document bool, unescape bool,
+) ([]byte, error) {
 	var expandedTagContent []byte
 	var replacement []byte
+	var format Format
 
 	// Check recursion limits
 	if depth > maxExpansionLevels {
@@ -28,7 +42,12 @@ func expandVars(ctx context.Context, data []byte, depth int) ([]byte, error) {
 	}
 
 	// Create a new data processor
-	p := preprocessor.New(data)
+	p := preprocessor.New(data, syntax, unescape, func(name string) interface{} {
+		if handler := lookupMacro(ctx, name); handler != nil {
+			return handler
+		}
+		return nil
+	})
 
 	// Loop
 	for {
@@ -46,36 +65,125 @@ func expandVars(ctx context.Context, data []byte, depth int) ([]byte, error) {
 		}
 
 		// Expand variables that may appear inside the found content
-		expandedTagContent, err = expandVars(ctx, ti.Content, depth+1)
+		expandedTagContent, err = expandVars(ctx, ti.Content, syntax, depth+1, false, false)
 		if err != nil {
 			return nil, err
 		}
 
 		// Process tag
+		format = FormatAuto
+		isDocument := false
 		switch ti.Tag {
 		case preprocessor.TagSRC:
 			// Load data from the specified source
-			replacement, err = internalLoad(ctx, string(expandedTagContent))
+			replacement, format, err = internalLoad(ctx, string(expandedTagContent))
 			if err != nil {
 				return nil, err
 			}
+			isDocument = true
 
 		case preprocessor.TagENV:
 			// Get value from environment strings
 			v, found := os.LookupEnv(string(expandedTagContent))
-			if !found {
-				return nil, fmt.Errorf("environment variable '%v' not set", string(expandedTagContent))
+
+			// Apply the shell-like operator if any. Like in shells, empty values are treated as unset. The
+			// argument is returned unexpanded, so it is only expanded below when it is used.
+			switch ti.Operator {
+			case '-':
+				if len(v) == 0 {
+					v = string(ti.Argument)
+					isDocument = unescape
+				}
+
+			case '?':
+				if len(v) == 0 {
+					var msg []byte
+
+					msg, err = expandVars(ctx, ti.Argument, syntax, depth+1, false, unescape)
+					if err != nil {
+						return nil, err
+					}
+					if len(msg) == 0 {
+						return nil, fmt.Errorf("environment variable '%v' not set", string(expandedTagContent))
+					}
+					return nil, errors.New(string(msg))
+				}
+
+			case '+':
+				if len(v) > 0 {
+					v = string(ti.Argument)
+					isDocument = unescape
+				}
+
+			default:
+				if !found {
+					return nil, fmt.Errorf("environment variable '%v' not set", string(expandedTagContent))
+				}
 			}
 			replacement = []byte(v)
 
+		case preprocessor.TagVTRANSIT:
+			// Decrypt the ciphertext using Vault's Transit secrets engine
+			replacement, err = decryptWithVaultTransit(ctx, string(expandedTagContent))
+			if err != nil {
+				return nil, err
+			}
+
+		case preprocessor.TagFILE:
+			// Read the file contents
+			replacement, err = loadFileMacro(ctx, string(expandedTagContent))
+			if err != nil {
+				return nil, err
+			}
+
+		case preprocessor.TagCustom:
+			// Call the handler of the custom macro
+			handler, ok := ti.Handler.(MacroHandler)
+			if !ok {
+				return nil, errors.New("unexpected")
+			}
+			replacement, err = handler(ctx, string(expandedTagContent))
+			if err != nil {
+				return nil, fmt.Errorf("macro '%s' failed: %w", ti.Name, err)
+			}
+
 		default:
 			return nil, errors.New("unexpected")
 		}
 
-		// Recursively expand variables inside loaded data
-		replacement, err = expandVars(ctx, replacement, depth+1)
-		if err != nil {
-			return nil, err
+		// Check if the replacement must be inserted as a string. File contents are by default.
+		inString := document && ti.InString
+		asString := ti.Mode == preprocessor.ModeJSON ||
+			(ti.Mode == preprocessor.ModeAuto && (inString || ti.Tag == preprocessor.TagFILE))
+
+		if ti.Tag != preprocessor.TagFILE {
+			// Recursively expand variables inside loaded data. Loaded documents and arguments, which are part of
+			// the document, are also unescaped. Loaded documents have their own format.
+			replacementSyntax := syntax
+			if ti.Tag == preprocessor.TagSRC {
+				replacementSyntax = documentSyntax(replacement, format)
+			}
+			replacement, err = expandVars(ctx, replacement, replacementSyntax, depth+1, !asString, isDocument)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if asString {
+			// Escape the replacement so it cannot break the document
+			replacement, err = encodeJSON(string(replacement))
+			if err != nil {
+				return nil, err
+			}
+			if inString {
+				replacement = replacement[1 : len(replacement)-1]
+			}
+		} else if format != FormatAuto {
+			// Convert loaded documents to json if they are in another format
+			replacement, err = convertToJSON(replacement, format)
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		// Execute replacement
@@ -85,3 +193,18 @@ func expandVars(ctx context.Context, data []byte, depth int) ([]byte, error) {
 	// Done
 	return p.Data, nil
 }
+
+// documentSyntax returns the syntax used to locate the string literals of a document in the given format
+func documentSyntax(data []byte, format Format) preprocessor.Syntax {
+	if format == FormatAuto {
+		format = detectFormat(data)
+	}
+
+	switch format {
+	case FormatYAML:
+		return preprocessor.SyntaxYAML
+	case FormatTOML:
+		return preprocessor.SyntaxTOML
+	}
+	return preprocessor.SyntaxJSON
+}
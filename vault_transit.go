@@ -0,0 +1,82 @@
+package go_config_reader
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultVaultTransitMount = "transit"
+)
+
+// -----------------------------------------------------------------------------
+
+// decryptWithVaultTransit decrypts the ciphertext specified in a ${VTRANSIT:key-name:ciphertext} macro. The server
+// address and credentials are taken from the options.
+func decryptWithVaultTransit(ctx context.Context, content string) ([]byte, error) {
+	// Split key name and ciphertext. The latter, like vault:v1:xxxx, contains colons.
+	i := strings.Index(content, ":")
+	if i <= 0 {
+		return nil, errors.New("invalid transit key name")
+	}
+	keyName := content[:i]
+	ciphertext := content[i+1:]
+	if len(ciphertext) == 0 {
+		return nil, errors.New("invalid transit ciphertext")
+	}
+
+	src := &vaultSource{}
+	resolveVaultOptions(ctx, src)
+	src.address = src.opts.Address
+	if len(src.address) == 0 {
+		src.address = os.Getenv(api.EnvVaultAddress)
+	}
+
+	// Create accessor
+	client, err := newVaultClient(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get an access token
+	err = vaultAuthenticate(ctx, client, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt
+	mount := strings.Trim(src.opts.TransitMount, "/")
+	if len(mount) == 0 {
+		mount = defaultVaultTransitMount
+	}
+	secret, err := client.Logical().WriteWithContext(ctx, mount+"/decrypt/"+url.PathEscape(keyName), map[string]interface{}{
+		"ciphertext": ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("transit decryption failed")
+	}
+	plaintext, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("plaintext not found")
+	}
+
+	// The plaintext is base64 encoded
+	decoded, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, errors.New("invalid plaintext")
+	}
+
+	// Done
+	return decoded, nil
+}
@@ -0,0 +1,323 @@
+package go_config_reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultReloadDebounce   = 250 * time.Millisecond
+	defaultReloadInterval   = 30 * time.Second
+	defaultReloadMaxBackoff = 5 * time.Minute
+)
+
+// -----------------------------------------------------------------------------
+
+// ReloadOptions indicates configurable options used while watching a configuration for changes.
+type ReloadOptions struct {
+	// Time to wait for more changes before reloading the configuration. Defaults to 250ms.
+	Debounce time.Duration
+
+	// Time between checks on sources that must be polled, like web urls. Defaults to 30s.
+	PollInterval time.Duration
+
+	// Maximum random time added to each poll interval in order to spread the load on the servers.
+	PollJitter time.Duration
+
+	// Maximum time between checks when they fail. The poll interval is doubled on each failure until it
+	// reaches this value. Defaults to 5m.
+	MaxBackoff time.Duration
+}
+
+// ChangeCallback is a function to call when a watched configuration changes. On success, settings contains a
+// new object of the same type as the one passed to Watch. If the new configuration cannot be loaded or it does
+// not validate, settings is nil, err contains the reason and the previous configuration should remain in use.
+type ChangeCallback func(settings interface{}, err error)
+
+// Watcher monitors the sources of a configuration.
+type Watcher struct {
+	done    chan struct{}
+	mtx     sync.Mutex
+	sources []SourceInfo
+}
+
+// SourceInfo describes a source used by the configuration in use.
+type SourceInfo struct {
+	// The source as specified in the options or in a ${SRC:...} macro.
+	Source string
+
+	// Format of the loaded content.
+	Format Format
+
+	// Loader specific information about the loaded content. S3 objects report their "etag" and, if the bucket is
+	// versioned, their "version_id".
+	Metadata map[string]string
+}
+
+// -----------------------------------------------------------------------------
+
+// Watch loads the settings like Load does and then monitors the sources, including the ones referenced by
+// ${SRC:...} macros, for changes until the context is done. On each change, the whole configuration is loaded
+// and validated again and the callback is called only if it is different from the previous one.
+func Watch(ctx context.Context, options Options, settings interface{}, onChange ChangeCallback) (*Watcher, error) {
+	if onChange == nil {
+		return nil, errors.New("invalid callback")
+	}
+	settingsType := reflect.TypeOf(settings)
+	if settingsType == nil || settingsType.Kind() != reflect.Ptr {
+		return nil, errors.New("settings must be a pointer")
+	}
+	options.Context = ctx
+
+	// Do the initial load
+	s, encodedJSON, err := loadSession(ctx, &options)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeSettings(&options, encodedJSON, settings)
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+
+	// Start monitoring
+	w := Watcher{
+		done: make(chan struct{}),
+	}
+	w.setSources(s)
+	go w.run(ctx, &options, settingsType.Elem(), s, encodedJSON, onChange)
+
+	// Done
+	return &w, nil
+}
+
+// Wait blocks until the watcher stops.
+func (w *Watcher) Wait() {
+	<-w.done
+}
+
+// Sources returns the sources used by the configuration in use. They are updated before the callback is called with
+// new settings.
+func (w *Watcher) Sources() []SourceInfo {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.sources
+}
+
+func (w *Watcher) setSources(s *session) {
+	sources := make([]SourceInfo, 0, len(s.sources))
+	for _, ls := range s.sources {
+		sources = append(sources, SourceInfo{
+			Source:   ls.source,
+			Format:   ls.format,
+			Metadata: ls.metadata,
+		})
+	}
+
+	w.mtx.Lock()
+	w.sources = sources
+	w.mtx.Unlock()
+}
+
+func (w *Watcher) run(
+	ctx context.Context, options *Options, settingsType reflect.Type, s *session, lastJSON []byte,
+	onChange ChangeCallback,
+) {
+	var timerCh <-chan time.Time
+
+	defer close(w.done)
+
+	debounce := options.Reload.Debounce
+	if debounce <= 0 {
+		debounce = defaultReloadDebounce
+	}
+
+	changeCh := make(chan struct{}, 1)
+	errCh := make(chan error, 1)
+	notify := func() {
+		select {
+		case changeCh <- struct{}{}:
+		default:
+		}
+	}
+
+	stopWatchers := startSourceWatchers(ctx, s, notify, errCh)
+	defer func() {
+		stopWatchers()
+
+		// Release the resources held by the sources in use, like vault leases
+		s.release()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err := <-errCh:
+			onChange(nil, err)
+
+		case <-changeCh:
+			// Wait until changes settle down
+			timerCh = time.After(debounce)
+
+		case <-timerCh:
+			timerCh = nil
+
+			newSession, encodedJSON, err := loadSession(ctx, options)
+			if err != nil {
+				if ctx.Err() == nil {
+					onChange(nil, err)
+				}
+				continue
+			}
+			if bytes.Equal(encodedJSON, lastJSON) {
+				newSession.release()
+				continue
+			}
+
+			newSettings := reflect.New(settingsType).Interface()
+			err = decodeSettings(options, encodedJSON, newSettings)
+			if err != nil {
+				newSession.release()
+				onChange(nil, err)
+				continue
+			}
+
+			// The set of sources may have changed so restart the watchers
+			stopWatchers()
+			stopWatchers = startSourceWatchers(ctx, newSession, notify, errCh)
+			oldSession := s
+			s = newSession
+			w.setSources(s)
+
+			lastJSON = encodedJSON
+			onChange(newSettings, nil)
+
+			// The application switched to the new settings, so the resources of the previous ones can be released
+			oldSession.release()
+		}
+	}
+}
+
+// startSourceWatchers starts watching the sources loaded in a session and returns a function to stop them. Watchers
+// that fail are restarted.
+func startSourceWatchers(ctx context.Context, s *session, notify func(), errCh chan<- error) func() {
+	var wg sync.WaitGroup
+
+	watchCtx, cancelWatchers := context.WithCancel(ctx)
+	watchCtx = withSession(watchCtx, s)
+
+	for _, ls := range s.sources {
+		sw, ok := ls.loader.(sourceWatcher)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(ls *loadedSource) {
+			defer wg.Done()
+
+			opts := getReloadOptions(watchCtx)
+			failures := 0
+			for {
+				startTime := time.Now()
+
+				// Watchers return without error if there is nothing to watch
+				err := sw.watch(watchCtx, ls, notify)
+				if err == nil || watchCtx.Err() != nil {
+					return
+				}
+				select {
+				case errCh <- err:
+				case <-watchCtx.Done():
+					return
+				}
+
+				// Restart the watcher using an exponential backoff. Failures are forgotten if it ran for a while.
+				if time.Since(startTime) > pollDelay(opts, failures) {
+					failures = 0
+				}
+				failures += 1
+
+				select {
+				case <-watchCtx.Done():
+					return
+				case <-time.After(pollDelay(opts, failures)):
+				}
+
+				// The source may have changed while it was not being watched
+				notify()
+			}
+		}(ls)
+	}
+
+	return func() {
+		cancelWatchers()
+		wg.Wait()
+	}
+}
+
+// poll calls the check function periodically until the context is done. Failed checks are retried using an
+// exponential backoff.
+func poll(ctx context.Context, check func() error) error {
+	opts := getReloadOptions(ctx)
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pollDelay(opts, failures)):
+		}
+
+		if err := check(); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures += 1
+		} else {
+			failures = 0
+		}
+	}
+}
+
+// getReloadOptions returns the reload options of the session being watched
+func getReloadOptions(ctx context.Context) ReloadOptions {
+	if s := getSession(ctx); s != nil {
+		return s.options.Reload
+	}
+	return ReloadOptions{}
+}
+
+// pollDelay calculates the time to wait before the next check
+func pollDelay(opts ReloadOptions, failures int) time.Duration {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultReloadMaxBackoff
+	}
+
+	for i := 0; i < failures && interval < maxBackoff; i++ {
+		interval *= 2
+		if interval > maxBackoff {
+			interval = maxBackoff
+		}
+	}
+
+	if opts.PollJitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(opts.PollJitter)))
+	}
+	return interval
+}